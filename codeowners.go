@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Locations GitHub looks for a CODEOWNERS file, in order of precedence
+var CODEOWNERS_PATHS = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+const (
+	// Owners of the changed files are mentioned but not requested unless they are picked with --select
+	CodeOwnersSuggest = "suggest"
+	// Owners of the changed files are requested as reviewers
+	CodeOwnersRequest = "request"
+	CodeOwnersOff     = "off"
+)
+
+type CodeOwnersRule struct {
+	Pattern string
+	// Users and teams as `@user` or `@org/team`, or email addresses
+	Owners []string
+	regex  *regexp.Regexp
+}
+
+// Converts a CODEOWNERS pattern, which follows most of the gitignore rules, to a regular expression
+func codeOwnersRegex(pattern string) (*regexp.Regexp, error) {
+	directory := strings.HasSuffix(pattern, "/")
+	trimmed := strings.TrimSuffix(pattern, "/")
+
+	// Patterns with a slash anywhere but at the end are relative to the root of the repository
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+
+	var expression strings.Builder
+	if anchored {
+		expression.WriteString("^")
+	} else {
+		expression.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		switch {
+		case strings.HasPrefix(trimmed[i:], "**/"):
+			expression.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(trimmed[i:], "**"):
+			expression.WriteString(".*")
+			i++
+		case trimmed[i] == '*':
+			expression.WriteString("[^/]*")
+		case trimmed[i] == '?':
+			expression.WriteString("[^/]")
+		default:
+			expression.WriteString(regexp.QuoteMeta(trimmed[i : i+1]))
+		}
+	}
+
+	switch {
+	case directory:
+		expression.WriteString("/.*$")
+	case strings.HasSuffix(trimmed, "/*"):
+		// `docs/*` only covers the files directly in docs, not the ones in its subdirectories
+		expression.WriteString("$")
+	default:
+		expression.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(expression.String())
+}
+
+func parseCodeOwners(content string) []CodeOwnersRule {
+	var rules []CodeOwnersRule
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		regex, err := codeOwnersRegex(fields[0])
+		if err != nil {
+			log.Debug("Skipping invalid CODEOWNERS pattern", "pattern", fields[0], "error", err)
+			continue
+		}
+
+		rules = append(rules, CodeOwnersRule{
+			Pattern: fields[0],
+			Owners:  fields[1:],
+			regex:   regex,
+		})
+	}
+
+	return rules
+}
+
+// The last matching rule takes precedence, nil is returned when no rule matches
+func matchCodeOwners(rules []CodeOwnersRule, path string) *CodeOwnersRule {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].regex.MatchString(path) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+// Changed files grouped by the CODEOWNERS rule that owns them
+type OwnedArea struct {
+	Pattern string
+	Owners  []string
+	Files   []string
+}
+
+// Reads the CODEOWNERS file at the given revision and determines who owns the changed files
+func getCodeOwners(repo Repository, revision string, files []string) ([]OwnedArea, error) {
+	var content string
+	for _, path := range CODEOWNERS_PATHS {
+		file, err := repo.FileAtRevision(revision, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if file != "" {
+			log.Debug("Found CODEOWNERS", "path", path)
+			content = file
+			break
+		}
+	}
+
+	rules := parseCodeOwners(content)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var areas []OwnedArea
+	indices := map[string]int{}
+	for _, file := range files {
+		rule := matchCodeOwners(rules, file)
+		if rule == nil || len(rule.Owners) == 0 {
+			continue
+		}
+
+		index, ok := indices[rule.Pattern]
+		if !ok {
+			index = len(areas)
+			indices[rule.Pattern] = index
+			areas = append(areas, OwnedArea{Pattern: rule.Pattern, Owners: rule.Owners})
+		}
+
+		areas[index].Files = append(areas[index].Files, file)
+	}
+
+	return areas, nil
+}
+
+// Splits the owners in users and team slugs that can be requested as reviewers, email addresses can't be requested
+func codeOwnerReviewers(areas []OwnedArea) ([]string, []string) {
+	var users, teams []string
+	for _, area := range areas {
+		for _, owner := range area.Owners {
+			if !strings.HasPrefix(owner, "@") {
+				continue
+			}
+
+			if _, team, ok := strings.Cut(owner[1:], "/"); ok {
+				teams = appendUnique(teams, team)
+			} else {
+				users = appendUnique(users, owner[1:])
+			}
+		}
+	}
+
+	return users, teams
+}
+
+// Lists the owners of every area of the codebase the pull request touches
+func generateReviewersSection(areas []OwnedArea) string {
+	if len(areas) == 0 {
+		return ""
+	}
+
+	var lines []string
+	for _, area := range areas {
+		files := fmt.Sprintf("%d files", len(area.Files))
+		if len(area.Files) == 1 {
+			files = "1 file"
+		}
+
+		lines = append(lines, fmt.Sprintf("- `%s` (%s): %s", area.Pattern, files, strings.Join(area.Owners, ", ")))
+	}
+
+	return "## Reviewers\n\n" + strings.Join(lines, "\n")
+}
+
+func appendUnique(values []string, additions ...string) []string {
+	result := slices.Clone(values)
+	for _, addition := range additions {
+		if !slices.Contains(result, addition) {
+			result = append(result, addition)
+		}
+	}
+
+	return result
+}
+
+// Adds the code owners of the changed files to the reviewers depending on the configured mode
+func (p *Propr) addCodeOwners() {
+	users, teams := codeOwnerReviewers(p.owners)
+	if len(users) == 0 && len(teams) == 0 {
+		return
+	}
+
+	switch CONFIG.Data.CodeOwners {
+	case CodeOwnersOff:
+		return
+	case CodeOwnersRequest:
+		p.metadata.Reviewers = appendUnique(p.metadata.Reviewers, users...)
+		p.metadata.TeamReviewers = appendUnique(p.metadata.TeamReviewers, teams...)
+	default:
+		// Preselect the owners so they only have to be confirmed, teams can't be picked so they are requested right away
+		if p.selectMetadata {
+			p.metadata.Reviewers = appendUnique(p.metadata.Reviewers, users...)
+			p.metadata.TeamReviewers = appendUnique(p.metadata.TeamReviewers, teams...)
+			if len(teams) > 0 {
+				log.Info("Requesting the review of the teams that own the changed files", "teams", teams)
+			}
+
+			return
+		}
+
+		log.Info("The changed files have code owners, pass --select or set code_owners to request to request their review", "users", users, "teams", teams)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddCodeOwners(t *testing.T) {
+	previous := CONFIG.Data.CodeOwners
+	t.Cleanup(func() { CONFIG.Data.CodeOwners = previous })
+
+	owners := []OwnedArea{{Pattern: "/api/", Owners: []string{"@jane", "@acme/backend", "ops@example.com"}, Files: []string{"api/a.go"}}}
+	tests := []struct {
+		mode     string
+		selected bool
+		expected PullRequestMetadata
+	}{
+		{CodeOwnersSuggest, false, PullRequestMetadata{}},
+		{CodeOwnersSuggest, true, PullRequestMetadata{Reviewers: []string{"jane"}, TeamReviewers: []string{"backend"}}},
+		{CodeOwnersRequest, false, PullRequestMetadata{Reviewers: []string{"jane"}, TeamReviewers: []string{"backend"}}},
+		{CodeOwnersOff, true, PullRequestMetadata{}},
+	}
+
+	for _, test := range tests {
+		CONFIG.Data.CodeOwners = test.mode
+		p := &Propr{owners: owners, selectMetadata: test.selected}
+		p.addCodeOwners()
+
+		if !reflect.DeepEqual(p.metadata, test.expected) {
+			t.Errorf("%s (select %v): expected %#v, got %#v", test.mode, test.selected, test.expected, p.metadata)
+		}
+	}
+}
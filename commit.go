@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+type Commit struct {
+	Hash     string
+	Subject  string
+	Body     string
+	Trailers []Trailer
+}
+
+const (
+	TrailerCoAuthoredBy = "co-authored-by"
+	TrailerFixes        = "fixes"
+	TrailerCloses       = "closes"
+	TrailerResolves     = "resolves"
+	TrailerRefs         = "refs"
+)
+
+// Follows the rules of git interpret-trailers, a token without spaces followed by a colon.
+// Values starting with `//` are the rest of a URL like `https://example.com` rather than a trailer.
+var trailerRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s*(.+)$`)
+
+func parseTrailer(line string) (Trailer, bool) {
+	match := trailerRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil || strings.HasPrefix(match[2], "//") {
+		return Trailer{}, false
+	}
+
+	return Trailer{Key: match[1], Value: strings.TrimSpace(match[2])}, true
+}
+
+// Splits a commit message in its subject, body and the trailers in its last paragraph
+func parseCommit(hash string, message string) Commit {
+	message = strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n"))
+	subject, body, _ := strings.Cut(message, "\n")
+
+	commit := Commit{
+		Hash:    hash,
+		Subject: strings.TrimSpace(subject),
+		Body:    strings.TrimSpace(body),
+	}
+
+	paragraphs := strings.Split(commit.Body, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+
+	var trailers []Trailer
+	for _, line := range strings.Split(last, "\n") {
+		trailer, ok := parseTrailer(line)
+		if !ok {
+			// Only a paragraph consisting solely of trailers is a trailer block
+			return commit
+		}
+
+		trailers = append(trailers, trailer)
+	}
+
+	commit.Trailers = trailers
+	commit.Body = strings.TrimSpace(strings.Join(paragraphs[:len(paragraphs)-1], "\n\n"))
+
+	return commit
+}
+
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+
+	return c.Hash
+}
+
+func (c Commit) String() string {
+	parts := []string{c.ShortHash() + " " + c.Subject}
+	if c.Body != "" {
+		parts = append(parts, c.Body)
+	}
+
+	if len(c.Trailers) > 0 {
+		var trailers []string
+		for _, trailer := range c.Trailers {
+			trailers = append(trailers, trailer.Key+": "+trailer.Value)
+		}
+
+		parts = append(parts, strings.Join(trailers, "\n"))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// Collects the unique values of the given trailers across all commits, in order of appearance
+func collectTrailers(commits []Commit, keys ...string) []Trailer {
+	var result []Trailer
+	seen := map[string]bool{}
+
+	for _, commit := range commits {
+		for _, trailer := range commit.Trailers {
+			for _, key := range keys {
+				if !strings.EqualFold(trailer.Key, key) || seen[strings.ToLower(trailer.Value)] {
+					continue
+				}
+
+				seen[strings.ToLower(trailer.Value)] = true
+				result = append(result, trailer)
+			}
+		}
+	}
+
+	return result
+}
+
+// Strips the email address from a `Name <email>` identity
+func identityName(identity string) string {
+	if name, _, ok := strings.Cut(identity, "<"); ok && strings.TrimSpace(name) != "" {
+		return strings.TrimSpace(name)
+	}
+
+	return identity
+}
+
+// Renders the co-authors and referenced issues found in the commit trailers.
+// Closing keywords are kept so GitHub links and closes the issues when the pull request is merged.
+func generateTrailersSection(commits []Commit) string {
+	var sections []string
+
+	if coAuthors := collectTrailers(commits, TrailerCoAuthoredBy); len(coAuthors) > 0 {
+		var lines []string
+		for _, trailer := range coAuthors {
+			lines = append(lines, "- "+identityName(trailer.Value))
+		}
+
+		sections = append(sections, "## Co-authors\n\n"+strings.Join(lines, "\n"))
+	}
+
+	if issues := collectTrailers(commits, TrailerFixes, TrailerCloses, TrailerResolves, TrailerRefs); len(issues) > 0 {
+		var lines []string
+		for _, trailer := range issues {
+			keyword := strings.ToUpper(trailer.Key[:1]) + strings.ToLower(trailer.Key[1:])
+			lines = append(lines, fmt.Sprintf("- %s %s", keyword, trailer.Value))
+		}
+
+		sections = append(sections, "## Related issues\n\n"+strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
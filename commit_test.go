@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommit(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected Commit
+	}{
+		{
+			"subject only",
+			"Fix thing\n",
+			Commit{Hash: "abc", Subject: "Fix thing"},
+		},
+		{
+			"body and trailers",
+			"Fix thing\n\nThe thing was broken.\n\nCo-authored-by: Jane <jane@example.com>\nFixes: #12\n",
+			Commit{
+				Hash:     "abc",
+				Subject:  "Fix thing",
+				Body:     "The thing was broken.",
+				Trailers: []Trailer{{"Co-authored-by", "Jane <jane@example.com>"}, {"Fixes", "#12"}},
+			},
+		},
+		{
+			"body ending with a URL",
+			"Fix thing\n\nSee docs\n\nhttps://example.com/x",
+			Commit{Hash: "abc", Subject: "Fix thing", Body: "See docs\n\nhttps://example.com/x"},
+		},
+		{
+			"trailers mixed with text",
+			"Fix thing\n\nFixes: #12\nand some more text",
+			Commit{Hash: "abc", Subject: "Fix thing", Body: "Fixes: #12\nand some more text"},
+		},
+		{
+			"windows line endings",
+			"Fix thing\r\n\r\nRefs: #3\r\n",
+			Commit{Hash: "abc", Subject: "Fix thing", Trailers: []Trailer{{"Refs", "#3"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if commit := parseCommit("abc", test.message); !reflect.DeepEqual(commit, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, commit)
+			}
+		})
+	}
+}
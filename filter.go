@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// A single stage of the diff preparation pipeline. Every stage receives the chunks left over by the
+// previous stage and returns the chunks it kept together with a description of what it removed.
+type DiffFilter interface {
+	Name() string
+	Filter(chunks []string) ([]string, []string)
+}
+
+// The revisions the diff was taken between, for filters that need to look at the files as a whole
+type DiffRange struct {
+	Repo Repository
+	Base string
+	Head string
+}
+
+const (
+	FilterIgnore     = "ignore"
+	FilterGenerated  = "generated"
+	FilterNotebook   = "notebook"
+	FilterMinified   = "minified"
+	FilterFormatting = "formatting"
+	FilterRedact     = "redact"
+	FilterWhitespace = "whitespace"
+	FilterTruncate   = "truncate"
+)
+
+var DEFAULT_FILTERS = []string{
+	FilterIgnore,
+	FilterGenerated,
+	FilterNotebook,
+	FilterMinified,
+	FilterFormatting,
+	FilterRedact,
+	FilterWhitespace,
+	FilterTruncate,
+}
+
+var DIFF_FILTERS = map[string]func(DiffRange) DiffFilter{
+	FilterIgnore:     func(DiffRange) DiffFilter { return &IgnoreFilter{files: FILES_TO_IGNORE} },
+	FilterGenerated:  func(r DiffRange) DiffFilter { return &GeneratedFilter{diffRange: r} },
+	FilterNotebook:   func(r DiffRange) DiffFilter { return &NotebookFilter{diffRange: r} },
+	FilterMinified:   func(DiffRange) DiffFilter { return &MinifiedFilter{} },
+	FilterFormatting: func(r DiffRange) DiffFilter { return &FormattingFilter{diffRange: r} },
+	FilterRedact:     func(DiffRange) DiffFilter { return &RedactFilter{} },
+	FilterWhitespace: func(DiffRange) DiffFilter { return &WhitespaceFilter{} },
+	FilterTruncate: func(DiffRange) DiffFilter {
+		return &TruncateFilter{maxChunkLength: MAX_CHUNK_LENGTH, maxDiffLength: MAX_DIFF_LENGTH}
+	},
+}
+
+type DiffPipeline struct {
+	filters []DiffFilter
+}
+
+// Builds a pipeline that runs the named filters in the given order
+func NewDiffPipeline(names []string, diffRange DiffRange) (*DiffPipeline, error) {
+	pipeline := &DiffPipeline{}
+
+	for _, name := range names {
+		constructor, ok := DIFF_FILTERS[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown diff filter %q", name)
+		}
+
+		pipeline.filters = append(pipeline.filters, constructor(diffRange))
+	}
+
+	return pipeline, nil
+}
+
+func (p *DiffPipeline) Run(chunks []string) []string {
+	for _, filter := range p.filters {
+		var removed []string
+		chunks, removed = filter.Filter(chunks)
+
+		if len(removed) > 0 {
+			log.Debug("Diff filter applied", "filter", filter.Name(), "removed", removed)
+		}
+	}
+
+	return chunks
+}
+
+var _ DiffFilter = (*IgnoreFilter)(nil)
+
+// Drops chunks of files that carry no meaning for a description, like lock files
+type IgnoreFilter struct {
+	files []string
+}
+
+func (f *IgnoreFilter) Name() string {
+	return FilterIgnore
+}
+
+func (f *IgnoreFilter) Filter(chunks []string) ([]string, []string) {
+	var result, removed []string
+
+	for _, chunk := range chunks {
+		path := getChunkPath(chunk)
+		shouldIgnore := false
+
+		for _, file := range f.files {
+			if filepath.Base(path) == file {
+				shouldIgnore = true
+				break
+			}
+		}
+
+		if shouldIgnore {
+			removed = append(removed, path)
+			continue
+		}
+
+		result = append(result, chunk)
+	}
+
+	return result, removed
+}
+
+var _ DiffFilter = (*GeneratedFilter)(nil)
+
+var (
+	// https://go.dev/s/generatedcode
+	goGeneratedMarkerRegex = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+	generatedPathRegex     = regexp.MustCompile(`(\.pb\.go|_gen\.go|\.gen\.go|_generated\.go|\.generated\.[a-z]+|_pb2\.py|\.snap)$`)
+	// Hunks that start at the first line of the file, where the leading comment block lives
+	firstLineHunkRegex = regexp.MustCompile(`^@@ -\d+(,\d+)? \+1(,\d+)? @@`)
+	commentPrefixes    = []string{"//", "#", "/*", "*", "<!--", "--", ";"}
+)
+
+func isCommentLine(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+
+	for _, prefix := range commentPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Only a marker in the leading comment block of a file marks it as generated,
+// a marker anywhere else is more likely a comment or string that mentions generated code
+func hasGeneratedMarker(source string) bool {
+	for _, line := range strings.Split(source, "\n") {
+		if !isCommentLine(line) {
+			return false
+		}
+
+		if goGeneratedMarkerRegex.MatchString(strings.TrimSpace(line)) || strings.Contains(line, "@generated") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reconstructs the start of the new file from a hunk that starts at its first line
+func leadingLines(chunk string) string {
+	var lines []string
+	leading := false
+	for _, line := range strings.Split(chunk, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			if leading {
+				break
+			}
+
+			leading = firstLineHunkRegex.MatchString(line)
+			continue
+		}
+
+		if leading && line != "" && line[0] != '-' {
+			lines = append(lines, line[1:])
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Drops chunks of files that were generated by a tool rather than written by hand
+type GeneratedFilter struct {
+	diffRange DiffRange
+}
+
+func (f *GeneratedFilter) Name() string {
+	return FilterGenerated
+}
+
+// A file that was generated stays generated, so the marker is looked for in the file itself rather than in the changes.
+// Deleted files are looked up at the base, and the diff is the last resort when the file can't be read.
+func (f *GeneratedFilter) isGenerated(chunk string, path string) bool {
+	if f.diffRange.Repo != nil {
+		for _, revision := range []string{f.diffRange.Head, f.diffRange.Base} {
+			source, err := f.diffRange.Repo.FileAtRevision(revision, path)
+			if err != nil {
+				log.Debug("Failed to read file", "path", path, "revision", revision, "error", err)
+				break
+			}
+
+			if source != "" {
+				return hasGeneratedMarker(source)
+			}
+		}
+	}
+
+	return hasGeneratedMarker(leadingLines(chunk))
+}
+
+func (f *GeneratedFilter) Filter(chunks []string) ([]string, []string) {
+	var result, removed []string
+
+	for _, chunk := range chunks {
+		path := getChunkPath(chunk)
+		if generatedPathRegex.MatchString(path) || f.isGenerated(chunk, path) {
+			removed = append(removed, path)
+			continue
+		}
+
+		result = append(result, chunk)
+	}
+
+	return result, removed
+}
+
+var _ DiffFilter = (*RedactFilter)(nil)
+
+// Replaces secrets with placeholders so they never leave the machine
+type RedactFilter struct{}
+
+func (f *RedactFilter) Name() string {
+	return FilterRedact
+}
+
+func (f *RedactFilter) Filter(chunks []string) ([]string, []string) {
+	result, files := redactChunks(chunks)
+	if len(files) > 0 {
+		log.Warn("Redacted potential secrets from the diff, use --no-redact to disable", "files", files)
+	}
+
+	return result, files
+}
+
+var _ DiffFilter = (*WhitespaceFilter)(nil)
+
+// Strips trailing whitespace and collapses consecutive blank lines to save on tokens
+type WhitespaceFilter struct{}
+
+func (f *WhitespaceFilter) Name() string {
+	return FilterWhitespace
+}
+
+func (f *WhitespaceFilter) Filter(chunks []string) ([]string, []string) {
+	var removed []string
+
+	result := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		lines := strings.Split(chunk, "\n")
+		collapsed := make([]string, 0, len(lines))
+
+		for _, line := range lines {
+			// Keep the diff prefix of the line intact, even if it is a space
+			if len(line) > 1 {
+				line = line[:1] + strings.TrimRight(line[1:], " \t\r")
+			}
+
+			// A blank line in a diff is only its prefix, drop it when the previous line is the exact same
+			if len(collapsed) > 0 && len(line) <= 1 && line == collapsed[len(collapsed)-1] {
+				continue
+			}
+
+			collapsed = append(collapsed, line)
+		}
+
+		if count := len(lines) - len(collapsed); count > 0 {
+			removed = append(removed, fmt.Sprintf("%d blank lines in %s", count, getChunkPath(chunk)))
+		}
+
+		result[i] = strings.Join(collapsed, "\n")
+	}
+
+	return result, removed
+}
+
+var _ DiffFilter = (*FormattingFilter)(nil)
+
+// Collapses files of which the changes vanish when ignoring whitespace into a single note,
+// so running a formatter across a package doesn't drown out the substantive changes
+type FormattingFilter struct {
+	diffRange DiffRange
+}
+
+func (f *FormattingFilter) Name() string {
+	return FilterFormatting
+}
+
+func (f *FormattingFilter) Filter(chunks []string) ([]string, []string) {
+	diff, err := f.diffRange.Repo.WhitespaceInsensitiveDiff(f.diffRange.Base, f.diffRange.Head)
+	if err != nil {
+		log.Debug("Failed to fetch whitespace insensitive diff", "error", err)
+		return chunks, nil
+	}
+
+	substantive := map[string]bool{}
+	for _, chunk := range splitDiffIntoChunks(diff) {
+		substantive[getChunkPath(chunk)] = true
+	}
+
+	var result, removed []string
+	for _, chunk := range chunks {
+		path := getChunkPath(chunk)
+
+		// Renames, mode changes and binary files have no hunks and are kept as is
+		if !substantive[path] && strings.Contains(chunk, "\n@@ ") {
+			removed = append(removed, path)
+			continue
+		}
+
+		result = append(result, chunk)
+	}
+
+	if len(removed) > 0 {
+		result = append(result, fmt.Sprintf("Formatting-only changes in %d files: %s", len(removed), strings.Join(removed, ", ")))
+	}
+
+	return result, removed
+}
+
+var _ DiffFilter = (*TruncateFilter)(nil)
+
+// Limit the size of a single file and the diff as a whole so we stay well within the context window
+const (
+	MAX_CHUNK_LENGTH = 20_000
+	MAX_DIFF_LENGTH  = 200_000
+)
+
+// Cuts off oversized chunks and drops whatever no longer fits in the total budget
+type TruncateFilter struct {
+	maxChunkLength int
+	maxDiffLength  int
+}
+
+func (f *TruncateFilter) Name() string {
+	return FilterTruncate
+}
+
+func (f *TruncateFilter) Filter(chunks []string) ([]string, []string) {
+	var result, removed []string
+
+	total := 0
+	for _, chunk := range chunks {
+		path := getChunkPath(chunk)
+
+		if total >= f.maxDiffLength {
+			removed = append(removed, path)
+			continue
+		}
+
+		if len(chunk) > f.maxChunkLength {
+			omitted := strings.Count(chunk[f.maxChunkLength:], "\n") + 1
+			chunk = fmt.Sprintf("%s\n... %d lines truncated", chunk[:f.maxChunkLength], omitted)
+			removed = append(removed, fmt.Sprintf("%d lines of %s", omitted, path))
+		}
+
+		total += len(chunk)
+		result = append(result, chunk)
+	}
+
+	return result, removed
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		diff      string
+		generated bool
+	}{
+		{
+			"new go file with marker",
+			"diff --git a/api.go b/api.go\nnew file mode 100644\n--- /dev/null\n+++ b/api.go\n@@ -0,0 +1,3 @@\n+// Code generated by protoc-gen-go. DO NOT EDIT.\n+\n+package api\n",
+			true,
+		},
+		{
+			"added @generated header",
+			"diff --git a/schema.ts b/schema.ts\n--- a/schema.ts\n+++ b/schema.ts\n@@ -1,2 +1,3 @@\n+/* @generated by codegen */\n import x from 'x'\n export const y = 1\n",
+			true,
+		},
+		{
+			"generated path",
+			"diff --git a/api.pb.go b/api.pb.go\n--- a/api.pb.go\n+++ b/api.pb.go\n@@ -10,1 +10,1 @@\n-a\n+b\n",
+			true,
+		},
+		{
+			"marker mentioned in a comment",
+			"diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -10,2 +10,3 @@\n func main() {\n+\t// the token is auto-generated by the server\n }\n",
+			false,
+		},
+		{
+			"marker in a string after the leading comment block",
+			"diff --git a/filter.go b/filter.go\n--- a/filter.go\n+++ b/filter.go\n@@ -1,3 +1,4 @@\n package main\n \n+var marker = \"// Code generated by x. DO NOT EDIT.\"\n+var tag = \"@generated\"\n",
+			false,
+		},
+		{
+			"existing marker in context",
+			"diff --git a/api.go b/api.go\n--- a/api.go\n+++ b/api.go\n@@ -1,3 +1,3 @@\n // Code generated by protoc-gen-go. DO NOT EDIT.\n \n-package old\n+package api\n",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := splitDiffIntoChunks(test.diff)
+			result, removed := (&GeneratedFilter{}).Filter(chunks)
+
+			if got := len(removed) > 0; got != test.generated {
+				t.Errorf("expected generated to be %v, removed %v", test.generated, removed)
+			}
+
+			if len(result)+len(removed) != len(chunks) || (len(removed) > 0 && !slices.Contains(removed, getChunkPath(chunks[0]))) {
+				t.Errorf("unexpected result %v and removed %v", result, removed)
+			}
+		})
+	}
+}
+
+func TestGeneratedFilterReadsFile(t *testing.T) {
+	dir := setupFixtureRepository(t)
+	writeFile(t, dir, "mock.go", "// Code generated by mockgen. DO NOT EDIT.\n\npackage mock\n\n"+strings.Repeat("var _ = 1\n", 20))
+	writeFile(t, dir, "main.go", "// Package main mentions @generated code in a string below\npackage main\n\n"+strings.Repeat("var _ = 1\n", 20))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add mock")
+	writeFile(t, dir, "mock.go", "// Code generated by mockgen. DO NOT EDIT.\n\npackage mock\n\n"+strings.Repeat("var _ = 1\n", 19)+"var _ = 2\n")
+	writeFile(t, dir, "main.go", "package main\n\n"+strings.Repeat("var _ = 1\n", 19)+"var _ = \"@generated\"\n")
+	runGit(t, dir, "commit", "--quiet", "-am", "Regenerate mock")
+
+	for name, repo := range openBackends(t, dir) {
+		diff, err := repo.Diff("HEAD~1", "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter := DIFF_FILTERS[FilterGenerated](DiffRange{Repo: repo, Base: "HEAD~1", Head: "HEAD"})
+		if _, removed := filter.Filter(splitDiffIntoChunks(diff)); !slices.Equal(removed, []string{"mock.go"}) {
+			t.Errorf("%s: expected only the regenerated mock to be removed, got %v", name, removed)
+		}
+	}
+}
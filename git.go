@@ -1,133 +1,392 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"os/exec"
-	"sort"
+	"path/filepath"
 	"strings"
 
 	"github.com/charmbracelet/log"
 )
 
-func getCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "branch", "--show-current")
-	stdout, err := cmd.Output()
+var _ Repository = (*GitCLI)(nil)
+
+// Repository backed by the git binary
+type GitCLI struct {
+	dir string
+}
+
+func NewGitCLI(dir string) *GitCLI {
+	return &GitCLI{
+		dir,
+	}
+}
+
+func (g *GitCLI) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+
+	return cmd
+}
+
+func (g *GitCLI) output(args ...string) (string, error) {
+	stdout, err := g.command(args...).Output()
 	if err != nil {
 		return "", err
 	}
 
-	return strings.TrimSpace(string(stdout)), nil
+	return string(stdout), nil
+}
+
+// Arguments to diff base with head, uncommitted heads are compared against base
+func diffRange(base string, head string) []string {
+	switch head {
+	case HeadStaged:
+		return []string{"--cached", base}
+	case HeadWorkingTree:
+		return []string{base}
+	default:
+		return []string{base + ".." + head}
+	}
+}
+
+func splitLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
 }
 
-func getAllBranches() ([]string, error) {
+func (g *GitCLI) CurrentBranch() (string, error) {
+	stdout, err := g.output("branch", "--show-current")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+func (g *GitCLI) Branches() ([]string, error) {
 	// First, make sure we have the latest remote branches
-	fetchCmd := exec.Command("git", "fetch", "--prune")
-	if err := fetchCmd.Run(); err != nil {
+	if err := g.command("fetch", "--prune").Run(); err != nil {
 		log.Debug("Failed to fetch remote branches", "error", err)
 	}
 
-	// Get local branches
-	localCmd := exec.Command("git", "branch", "--format=%(refname:short)")
-	localOut, err := localCmd.Output()
+	local, err := g.output("branch", "--format=%(refname:short)")
 	if err != nil {
 		return nil, err
 	}
 
-	// Get remote branches (excluding HEAD)
-	remoteCmd := exec.Command("git", "branch", "-r", "--format=%(refname:short)")
-	remoteOut, err := remoteCmd.Output()
+	remote, err := g.output("branch", "-r", "--format=%(refname:short)")
 	if err != nil {
 		return nil, err
 	}
 
-	// Process local branches
-	localBranches := strings.Split(strings.TrimSpace(string(localOut)), "\n")
+	return combineBranches(splitLines(local), splitLines(remote)), nil
+}
 
-	// Process remote branches and remove the "origin/" prefix
-	remoteBranches := strings.Split(strings.TrimSpace(string(remoteOut)), "\n")
-	var cleanedRemoteBranches []string
-	for _, branch := range remoteBranches {
-		if branch != "" && !strings.Contains(branch, "HEAD") {
-			// Remove the "origin/" prefix for display
-			parts := strings.SplitN(branch, "/", 2)
-			if len(parts) > 1 {
-				cleanedRemoteBranches = append(cleanedRemoteBranches, parts[1])
-			}
-		}
+func (g *GitCLI) MergedBranches(remote string, revision string) ([]string, error) {
+	stdout, err := g.output("for-each-ref", "--merged="+revision, "--format=%(refname)", "refs/heads", "refs/remotes/"+remote)
+	if err != nil {
+		return nil, err
 	}
 
-	// Combine and deduplicate branches
-	allBranches := make(map[string]bool)
-	for _, branch := range localBranches {
-		if branch != "" {
-			allBranches[branch] = true
+	var branches []string
+	for _, ref := range splitLines(stdout) {
+		if branch, ok := shortBranchRef(ref, remote); ok {
+			branches = append(branches, branch)
 		}
 	}
 
-	for _, branch := range cleanedRemoteBranches {
-		if branch != "" {
-			allBranches[branch] = true
+	return branches, nil
+}
+
+func (g *GitCLI) Diff(base string, head string) (string, error) {
+	return g.output(append([]string{"diff"}, diffRange(base, head)...)...)
+}
+
+// Go files are diffed with the builtin golang driver of git so hunks expand to the enclosing func or type
+const FUNCTION_CONTEXT_ATTRIBUTES = "*.go diff=golang\n"
+
+func (g *GitCLI) FunctionContextDiff(base string, head string) (string, error) {
+	attributes, err := os.CreateTemp("", "propr-attributes")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(attributes.Name())
+
+	if _, err := attributes.WriteString(FUNCTION_CONTEXT_ATTRIBUTES); err != nil {
+		return "", err
+	}
+
+	if err := attributes.Close(); err != nil {
+		return "", err
+	}
+
+	return g.output(append([]string{"-c", "core.attributesFile=" + attributes.Name(), "diff", "--function-context"}, diffRange(base, head)...)...)
+}
+
+func (g *GitCLI) WhitespaceInsensitiveDiff(base string, head string) (string, error) {
+	return g.output(append([]string{"diff", "-w", "--ignore-blank-lines"}, diffRange(base, head)...)...)
+}
+
+func (g *GitCLI) DiffStat(base string, head string) (string, error) {
+	return g.output(append([]string{"diff", "--numstat", "--find-renames", "--summary"}, diffRange(base, head)...)...)
+}
+
+func (g *GitCLI) ChangedFiles(base string, head string) ([]string, error) {
+	stdout, err := g.output(append([]string{"diff", "--name-only"}, diffRange(base, head)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitLines(stdout), nil
+}
+
+func (g *GitCLI) FileAtRevision(revision string, path string) (string, error) {
+	switch revision {
+	case HeadStaged:
+		// Staged files are addressed without a revision
+		revision = ""
+	case HeadWorkingTree:
+		root, err := g.output("rev-parse", "--show-toplevel")
+		if err != nil {
+			return "", err
 		}
+
+		content, err := os.ReadFile(filepath.Join(strings.TrimSpace(root), path))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return string(content), err
+	}
+
+	if err := g.command("cat-file", "-e", revision+":"+path).Run(); err != nil {
+		return "", nil
+	}
+
+	return g.output("show", revision+":"+path)
+}
+
+// Separators that can't appear in a commit message to tell the hash, message and commits apart
+const (
+	logFieldSeparator  = "\x1f"
+	logRecordSeparator = "\x1e"
+)
+
+func (g *GitCLI) Log(base string, head string) ([]Commit, error) {
+	// Uncommitted work has no commits yet
+	if isUncommitted(head) {
+		return nil, nil
 	}
 
-	// Convert map to slice
-	var result []string
-	for branch := range allBranches {
-		result = append(result, branch)
+	stdout, err := g.output("log", "--format=%H%x1f%B%x1e", base+".."+head)
+	if err != nil {
+		return nil, err
 	}
 
-	// Sort branches alphabetically for better UX
-	sort.Strings(result)
+	var commits []Commit
+	for _, record := range strings.Split(stdout, logRecordSeparator) {
+		hash, message, ok := strings.Cut(strings.TrimSpace(record), logFieldSeparator)
+		if !ok {
+			continue
+		}
 
-	return result, nil
+		commits = append(commits, parseCommit(hash, message))
+	}
+
+	return commits, nil
 }
 
-func getDiff(current string, branch string) (string, error) {
-	cmd := exec.Command("git", "diff", "origin/"+branch+".."+current)
-	stdout, err := cmd.Output()
+func (g *GitCLI) Remotes() ([]string, error) {
+	stdout, err := g.output("remote")
+	if err != nil {
+		return nil, err
+	}
+
+	return splitLines(stdout), nil
+}
+
+func (g *GitCLI) RemoteURL(remote string) (string, error) {
+	stdout, err := g.output("config", "--get", "remote."+remote+".url")
 	if err != nil {
 		return "", err
 	}
 
-	return string(stdout), nil
+	return strings.TrimSpace(stdout), nil
 }
 
-func fetchRemoteOrigin() (string, error) {
-	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
-	stdout, err := cmd.Output()
+func (g *GitCLI) DefaultBranch(remote string) (string, error) {
+	// Set when cloning or by `git remote set-head`, which saves a network round trip
+	if stdout, err := g.output("symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD"); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(stdout), remote+"/"), nil
+	}
+
+	stdout, err := g.output("ls-remote", "--symref", remote, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to query the HEAD of %s", remote)
+	}
+
+	// The symbolic ref is listed as `ref: refs/heads/main	HEAD`
+	for _, line := range splitLines(stdout) {
+		ref, _, ok := strings.Cut(strings.TrimPrefix(line, "ref: "), "\t")
+		if ok && strings.HasPrefix(line, "ref: ") {
+			return strings.TrimPrefix(ref, "refs/heads/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s doesn't have a HEAD", remote)
+}
+
+func (g *GitCLI) MergeBase(a string, b string) (string, error) {
+	stdout, err := g.output("merge-base", a, b)
 	if err != nil {
 		return "", err
 	}
 
-	return strings.TrimSpace(string(stdout)), nil
+	return strings.TrimSpace(stdout), nil
 }
 
-type RepositoryInformation struct {
-	Name  string
-	Owner string
+func (g *GitCLI) HasRevision(revision string) bool {
+	return g.command("rev-parse", "--verify", "--quiet", revision+"^{commit}").Run() == nil
 }
 
-func getRepositoryInformation() (*RepositoryInformation, error) {
-	origin, err := fetchRemoteOrigin()
+func (g *GitCLI) ResolveRevision(revision string) (string, error) {
+	stdout, err := g.output("rev-parse", "--verify", "--quiet", revision+"^{commit}")
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("unknown revision %s", revision)
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+func (g *GitCLI) AheadBehind(base string, head string) (int, int, error) {
+	stdout, err := g.output("rev-list", "--left-right", "--count", base+"..."+head)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var behind, ahead int
+	if _, err := fmt.Sscan(stdout, &behind, &ahead); err != nil {
+		return 0, 0, err
+	}
+
+	return ahead, behind, nil
+}
+
+func (g *GitCLI) Upstream(branch string) (string, string, error) {
+	stdout, err := g.output("for-each-ref", "--format=%(upstream:remotename)%00%(upstream:remoteref)", "refs/heads/"+branch)
+	if err != nil {
+		return "", "", err
+	}
+
+	remote, ref, _ := strings.Cut(strings.TrimSpace(stdout), "\x00")
+	if remote == "" || ref == "" {
+		return "", "", nil
+	}
+
+	return remote, strings.TrimPrefix(ref, "refs/heads/"), nil
+}
+
+func (g *GitCLI) Push(remote string, branch string, remoteBranch string) error {
+	output, err := g.command("push", "--set-upstream", remote, branch+":"+remoteBranch).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to push %s to %s: %s", branch, remote, strings.TrimSpace(string(output)))
 	}
 
-	split := strings.Split(origin, "/")
-	name := strings.TrimSuffix(split[len(split)-1], ".git")
-	owner := split[len(split)-2]
+	return nil
+}
+
+func (g *GitCLI) Fetch(remote string, branch string) error {
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote, branch)
+
+	output, err := g.command("fetch", remote, refspec).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s from %s: %s", branch, remote, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Sticks to flags git 2.15 already understands, `--path-format` only arrived in 2.31
+func (g *GitCLI) Layout() (RepositoryLayout, error) {
+	stdout, err := g.output("rev-parse", "--is-bare-repository", "--is-shallow-repository", "--absolute-git-dir", "--git-common-dir")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return RepositoryLayout{}, fmt.Errorf("failed to inspect the repository in %s: %s", g.dir, strings.TrimSpace(string(exitErr.Stderr)))
+		}
 
-	return &RepositoryInformation{
-		Name:  name,
-		Owner: owner,
+		return RepositoryLayout{}, fmt.Errorf("failed to inspect the repository in %s: %w", g.dir, err)
+	}
+
+	lines := splitLines(stdout)
+	if len(lines) != 4 {
+		return RepositoryLayout{}, fmt.Errorf("unexpected output of git rev-parse: %q", stdout)
+	}
+
+	// The common dir is relative to the directory git was run in, unless it lives elsewhere like for worktrees
+	commonDir := lines[3]
+	if !filepath.IsAbs(commonDir) {
+		commonDir, err = filepath.Abs(filepath.Join(g.dir, commonDir))
+		if err != nil {
+			return RepositoryLayout{}, err
+		}
+	}
+
+	return RepositoryLayout{
+		Bare:    lines[0] == "true",
+		Shallow: lines[1] == "true",
+		// Git reports the git dir with symlinks resolved, so the common dir has to match for worktrees to be told apart
+		GitDir:    resolveSymlinks(lines[2]),
+		CommonDir: resolveSymlinks(commonDir),
 	}, nil
 }
 
-func getCommitMessages(current string, branch string) ([]string, error) {
-	cmd := exec.Command("git", "log", "--oneline", "origin/"+branch+".."+current)
+func resolveSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	return filepath.Clean(path)
+}
+
+// Diffs two versions of a file that don't live in the repository, like normalized contents
+func diffContents(before string, after string) (string, error) {
+	dir, err := os.MkdirTemp("", "propr-diff")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	beforePath, afterPath := filepath.Join(dir, "before"), filepath.Join(dir, "after")
+	if err := os.WriteFile(beforePath, []byte(before), 0o600); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(afterPath, []byte(after), 0o600); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "diff", "--no-index", "--no-color", beforePath, afterPath)
 	stdout, err := cmd.Output()
+
+	// Exit code 1 only signals that the files differ
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return strings.Split(string(stdout), "\n"), nil
+	return string(stdout), nil
 }
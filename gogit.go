@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var _ Repository = (*GoGit)(nil)
+
+// Repository backed by go-git for reading branches, commits, diffs and files without parsing the output of git.
+// Everything else is inherited from GitCLI and still runs the git binary: function context, whitespace insensitive
+// and uncommitted diffs, diff stats, the default branch, ahead/behind counts, the repository layout, fetching and
+// pushing with the credentials git is configured with.
+type GoGit struct {
+	*GitCLI
+	repo *git.Repository
+}
+
+func NewGoGit(dir string) (*GoGit, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GoGit{
+		NewGitCLI(dir),
+		repo,
+	}, nil
+}
+
+func (g *GoGit) commit(revision string) (*object.Commit, error) {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", revision, err)
+	}
+
+	return g.repo.CommitObject(*hash)
+}
+
+func (g *GoGit) patch(base string, head string) (*object.Patch, error) {
+	from, err := g.commit(base)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := g.commit(head)
+	if err != nil {
+		return nil, err
+	}
+
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect renames like git does by default, rather than reporting a deletion and an addition
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes.Patch()
+}
+
+func (g *GoGit) Upstream(branch string) (string, string, error) {
+	config, err := g.repo.Branch(branch)
+	if errors.Is(err, git.ErrBranchNotFound) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	if config.Remote == "" || config.Merge == "" {
+		return "", "", nil
+	}
+
+	return config.Remote, config.Merge.Short(), nil
+}
+
+func (g *GoGit) CurrentBranch() (string, error) {
+	head, err := g.repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	// Mimic `git branch --show-current` which prints nothing on a detached HEAD
+	if !head.Name().IsBranch() {
+		return "", nil
+	}
+
+	return head.Name().Short(), nil
+}
+
+func (g *GoGit) Branches() ([]string, error) {
+	// First, make sure we have the latest remote branches
+	if err := g.repo.Fetch(&git.FetchOptions{Prune: true}); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		log.Debug("Failed to fetch remote branches", "error", err)
+	}
+
+	references, err := g.repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var local, remote []string
+	err = references.ForEach(func(reference *plumbing.Reference) error {
+		switch {
+		case reference.Name().IsBranch():
+			local = append(local, reference.Name().Short())
+		case reference.Name().IsRemote():
+			remote = append(remote, reference.Name().Short())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return combineBranches(local, remote), nil
+}
+
+func (g *GoGit) MergedBranches(remote string, revision string) ([]string, error) {
+	commit, err := g.commit(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the history once instead of once per branch
+	reachable := map[plumbing.Hash]bool{}
+	err = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+		reachable[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	references, err := g.repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+	err = references.ForEach(func(reference *plumbing.Reference) error {
+		if reference.Type() != plumbing.HashReference || !reachable[reference.Hash()] {
+			return nil
+		}
+
+		if branch, ok := shortBranchRef(reference.Name().String(), remote); ok {
+			branches = append(branches, branch)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(branches)
+
+	return branches, nil
+}
+
+func (g *GoGit) Diff(base string, head string) (string, error) {
+	if isUncommitted(head) {
+		return g.GitCLI.Diff(base, head)
+	}
+
+	patch, err := g.patch(base, head)
+	if err != nil {
+		return "", err
+	}
+
+	return patch.String(), nil
+}
+
+func (g *GoGit) ChangedFiles(base string, head string) ([]string, error) {
+	if isUncommitted(head) {
+		return g.GitCLI.ChangedFiles(base, head)
+	}
+
+	patch, err := g.patch(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range patch.FilePatches() {
+		from, to := file.Files()
+
+		// Deleted files only have a source
+		if to != nil {
+			files = append(files, to.Path())
+		} else if from != nil {
+			files = append(files, from.Path())
+		}
+	}
+
+	return files, nil
+}
+
+func (g *GoGit) FileAtRevision(revision string, path string) (string, error) {
+	if isUncommitted(revision) {
+		return g.GitCLI.FileAtRevision(revision, path)
+	}
+
+	commit, err := g.commit(revision)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := commit.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return file.Contents()
+}
+
+func (g *GoGit) Log(base string, head string) ([]Commit, error) {
+	if isUncommitted(head) {
+		return nil, nil
+	}
+
+	from, err := g.commit(base)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything reachable from base is excluded, like `git log base..head`
+	excluded := map[plumbing.Hash]bool{}
+	iter := object.NewCommitPreorderIter(from, nil, nil)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		excluded[commit.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := g.commit(head)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	iter = object.NewCommitPreorderIter(to, excluded, nil)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		commits = append(commits, parseCommit(commit.Hash.String(), commit.Message))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+func (g *GoGit) Remotes() ([]string, error) {
+	remotes, err := g.repo.Remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, remote := range remotes {
+		names = append(names, remote.Config().Name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (g *GoGit) RemoteURL(remote string) (string, error) {
+	r, err := g.repo.Remote(remote)
+	if err != nil {
+		return "", err
+	}
+
+	urls := r.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %s has no URL", remote)
+	}
+
+	return urls[0], nil
+}
+
+func (g *GoGit) HasRevision(revision string) bool {
+	_, err := g.commit(revision)
+	return err == nil
+}
+
+func (g *GoGit) ResolveRevision(revision string) (string, error) {
+	commit, err := g.commit(revision)
+	if err != nil {
+		return "", err
+	}
+
+	return commit.Hash.String(), nil
+}
+
+func (g *GoGit) MergeBase(a string, b string) (string, error) {
+	first, err := g.commit(a)
+	if err != nil {
+		return "", err
+	}
+
+	second, err := g.commit(b)
+	if err != nil {
+		return "", err
+	}
+
+	bases, err := first.MergeBase(second)
+	if err != nil {
+		return "", err
+	}
+
+	if len(bases) == 0 {
+		return "", fmt.Errorf("no merge base found between %s and %s", a, b)
+	}
+
+	return bases[0].Hash.String(), nil
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+type APIDeclKind string
+
+const (
+	APIDeclFunc      APIDeclKind = "func"
+	APIDeclMethod    APIDeclKind = "method"
+	APIDeclType      APIDeclKind = "type"
+	APIDeclStruct    APIDeclKind = "struct"
+	APIDeclInterface APIDeclKind = "interface"
+)
+
+// An exported declaration of a Go package
+type APIDecl struct {
+	Name      string
+	Kind      APIDeclKind
+	Signature string
+	// Signature without parameter names, renaming a parameter doesn't break anyone
+	Shape string
+	// Declarations of main or internal packages can't be imported by other modules
+	Internal bool
+	// Exported fields of a struct or methods of an interface mapped to their type
+	Members map[string]string
+	// Type parameters of a generic type, eg. `[K comparable, V any]`
+	TypeParams string
+}
+
+// All exported declarations of a package, keyed by their qualified name
+type PackageAPI map[string]*APIDecl
+
+type APIChangeKind string
+
+const (
+	APIChangeAdded   APIChangeKind = "added"
+	APIChangeRemoved APIChangeKind = "removed"
+	APIChangeChanged APIChangeKind = "changed"
+)
+
+type APIChange struct {
+	Kind   APIChangeKind
+	Before *APIDecl
+	After  *APIDecl
+}
+
+func (c APIChange) Decl() *APIDecl {
+	if c.After != nil {
+		return c.After
+	}
+
+	return c.Before
+}
+
+func (c APIChange) String() string {
+	decl := c.Decl()
+
+	switch c.Kind {
+	case APIChangeChanged:
+		return fmt.Sprintf("- changed %s `%s`: `%s` -> `%s`", decl.Kind, decl.Name, c.Before.Signature, c.After.Signature)
+	default:
+		return fmt.Sprintf("- %s %s `%s`: `%s`", c.Kind, decl.Kind, decl.Name, decl.Signature)
+	}
+}
+
+func printNode(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+
+	// Collapse multi line declarations so they fit on a single line
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func receiverName(fset *token.FileSet, recv *ast.FieldList) (string, bool) {
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+
+	// Strip type parameters of generic receivers
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		expr = t.X
+	case *ast.IndexListExpr:
+		expr = t.X
+	}
+
+	ident, ok := expr.(*ast.Ident)
+	if !ok || !ident.IsExported() {
+		return "", false
+	}
+
+	return printNode(fset, recv.List[0].Type), true
+}
+
+func fieldNames(field *ast.Field, fset *token.FileSet) []string {
+	if len(field.Names) > 0 {
+		var names []string
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+
+		return names
+	}
+
+	// Embedded fields are named after their type
+	expr := field.Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+
+	if selector, ok := expr.(*ast.SelectorExpr); ok {
+		return []string{selector.Sel.Name}
+	}
+
+	return []string{printNode(fset, expr)}
+}
+
+func fieldListShape(fset *token.FileSet, list *ast.FieldList) string {
+	if list == nil {
+		return ""
+	}
+
+	var types []string
+	for _, field := range list.List {
+		for range max(1, len(field.Names)) {
+			types = append(types, printNode(fset, field.Type))
+		}
+	}
+
+	return strings.Join(types, ", ")
+}
+
+// Type parameters keep their names since the parameters and results refer to them
+func typeParamsShape(fset *token.FileSet, list *ast.FieldList) string {
+	if list == nil || len(list.List) == 0 {
+		return ""
+	}
+
+	var params []string
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			params = append(params, name.Name+" "+printNode(fset, field.Type))
+		}
+	}
+
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+func funcShape(fset *token.FileSet, fn *ast.FuncType) string {
+	return fmt.Sprintf("func%s(%s) (%s)", typeParamsShape(fset, fn.TypeParams), fieldListShape(fset, fn.Params), fieldListShape(fset, fn.Results))
+}
+
+func formatMembers(keyword string, members []string) string {
+	if len(members) == 0 {
+		return keyword + " {}"
+	}
+
+	return keyword + " { " + strings.Join(members, "; ") + " }"
+}
+
+func typeDecl(fset *token.FileSet, pkg string, spec *ast.TypeSpec) *APIDecl {
+	decl := &APIDecl{
+		Name:    pkg + "." + spec.Name.Name,
+		Kind:    APIDeclType,
+		Members: map[string]string{},
+	}
+
+	var members []string
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		decl.Kind = APIDeclStruct
+		for _, field := range t.Fields.List {
+			for _, name := range fieldNames(field, fset) {
+				if !ast.IsExported(name) {
+					continue
+				}
+
+				decl.Members[name] = printNode(fset, field.Type)
+				if len(field.Names) == 0 {
+					members = append(members, decl.Members[name])
+				} else {
+					members = append(members, name+" "+decl.Members[name])
+				}
+			}
+		}
+
+		decl.Signature = formatMembers("struct", members)
+	case *ast.InterfaceType:
+		decl.Kind = APIDeclInterface
+		for _, method := range t.Methods.List {
+			for _, name := range fieldNames(method, fset) {
+				if fn, ok := method.Type.(*ast.FuncType); ok {
+					decl.Members[name] = funcShape(fset, fn)
+					members = append(members, name+strings.TrimPrefix(printNode(fset, fn), "func"))
+				} else {
+					decl.Members[name] = printNode(fset, method.Type)
+					members = append(members, decl.Members[name])
+				}
+			}
+		}
+
+		decl.Signature = formatMembers("interface", members)
+	default:
+		decl.Signature = printNode(fset, spec.Type)
+		if spec.Assign.IsValid() {
+			decl.Signature = "= " + decl.Signature
+		}
+	}
+
+	// Changes to the type parameters are compared on their own
+	decl.Shape = decl.Signature
+
+	decl.TypeParams = typeParamsShape(fset, spec.TypeParams)
+	if decl.TypeParams != "" {
+		decl.Signature = decl.TypeParams + " " + decl.Signature
+	}
+
+	return decl
+}
+
+// Collects the exported functions, methods and types declared in the given source
+func extractExportedAPI(filename string, source string, pkg string, api PackageAPI) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, source, parser.SkipObjectResolution)
+	if err != nil {
+		return err
+	}
+
+	internal := file.Name.Name == "main" || isInternalPackage(pkg)
+
+	// Files in the root of the repository are labeled with their package name instead
+	if pkg == "." {
+		pkg = file.Name.Name
+	}
+
+	for _, node := range file.Decls {
+		switch decl := node.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+
+			name := pkg + "." + decl.Name.Name
+			kind := APIDeclFunc
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				receiver, ok := receiverName(fset, decl.Recv)
+				if !ok {
+					continue
+				}
+
+				name = fmt.Sprintf("%s.(%s).%s", pkg, receiver, decl.Name.Name)
+				kind = APIDeclMethod
+			}
+
+			api[name] = &APIDecl{
+				Name:      name,
+				Kind:      kind,
+				Signature: printNode(fset, decl.Type),
+				Shape:     funcShape(fset, decl.Type),
+				Internal:  internal,
+			}
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range decl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if !typeSpec.Name.IsExported() {
+					continue
+				}
+
+				api[pkg+"."+typeSpec.Name.Name] = typeDecl(fset, pkg, typeSpec)
+				api[pkg+"."+typeSpec.Name.Name].Internal = internal
+			}
+		}
+	}
+
+	return nil
+}
+
+func diffExportedAPI(before PackageAPI, after PackageAPI) []APIChange {
+	var changes []APIChange
+
+	for name, decl := range before {
+		other, ok := after[name]
+		if !ok {
+			changes = append(changes, APIChange{Kind: APIChangeRemoved, Before: decl})
+		} else if decl.Signature != other.Signature || decl.Kind != other.Kind {
+			changes = append(changes, APIChange{Kind: APIChangeChanged, Before: decl, After: other})
+		}
+	}
+
+	for name, decl := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, APIChange{Kind: APIChangeAdded, After: decl})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Decl().Name < changes[j].Decl().Name
+	})
+
+	return changes
+}
+
+func isInternalPackage(dir string) bool {
+	return dir == "internal" || strings.HasPrefix(dir, "internal/") || strings.Contains(dir, "/internal/") || strings.HasSuffix(dir, "/internal")
+}
+
+func isGoSourceFile(file string) bool {
+	return strings.HasSuffix(file, ".go") && !strings.HasSuffix(file, "_test.go") && !strings.HasPrefix(file, "vendor/")
+}
+
+// Parses the before and after versions of every changed Go file and compares their exported API.
+// Files are grouped per package so declarations that move between files aren't reported as changes.
+func getExportedAPIChanges(repo Repository, base string, head string) ([]APIChange, error) {
+	// The stat knows where renamed files came from, so their API can be compared with the one at the old path
+	stat, err := repo.DiffStat(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	before, after := PackageAPI{}, PackageAPI{}
+	collect := func(revision string, file string, api PackageAPI) error {
+		source, err := repo.FileAtRevision(revision, file)
+		if err != nil || source == "" {
+			return err
+		}
+
+		if err := extractExportedAPI(file, source, path.Dir(file), api); err != nil {
+			log.Debug("Failed to parse Go file", "file", file, "revision", revision, "error", err)
+		}
+
+		return nil
+	}
+
+	for _, file := range parseDiffStat(stat) {
+		oldPath := file.Path
+		if file.Status == FileStatusRenamed {
+			oldPath = file.OldPath
+		}
+
+		if isGoSourceFile(oldPath) {
+			if err := collect(base, oldPath, before); err != nil {
+				return nil, err
+			}
+		}
+
+		if isGoSourceFile(file.Path) {
+			if err := collect(head, file.Path, after); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return diffExportedAPI(before, after), nil
+}
+
+// Summarizes the changes to the exported Go API in a compact changelog for the model
+func summarizeAPIChanges(changes []APIChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	lines := []string{"Changes to the exported Go API:"}
+	for _, change := range changes {
+		if reasons := change.BreakingReasons(); len(reasons) > 0 {
+			lines = append(lines, fmt.Sprintf("%s (breaking: %s)", change, strings.Join(reasons, ", ")))
+		} else {
+			lines = append(lines, change.String())
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Explains why the change breaks consumers of the API, nothing is returned for compatible changes
+func (c APIChange) BreakingReasons() []string {
+	if c.Decl().Internal {
+		return nil
+	}
+
+	switch c.Kind {
+	case APIChangeAdded:
+		return nil
+	case APIChangeRemoved:
+		return []string{fmt.Sprintf("%s was removed", c.Before.Kind)}
+	}
+
+	if c.Before.Kind != c.After.Kind {
+		return []string{fmt.Sprintf("%s became a %s", c.Before.Kind, c.After.Kind)}
+	}
+
+	var reasons []string
+	if c.Before.TypeParams != c.After.TypeParams {
+		reasons = append(reasons, "type parameters changed")
+	}
+
+	switch c.Before.Kind {
+	case APIDeclStruct:
+		for _, name := range sortedKeys(c.Before.Members) {
+			kind, ok := c.After.Members[name]
+			if !ok {
+				reasons = append(reasons, fmt.Sprintf("field %s was removed", name))
+			} else if kind != c.Before.Members[name] {
+				reasons = append(reasons, fmt.Sprintf("field %s changed type", name))
+			}
+		}
+	case APIDeclInterface:
+		// Any addition to an interface breaks its existing implementations
+		for _, name := range sortedKeys(c.After.Members) {
+			if _, ok := c.Before.Members[name]; !ok {
+				reasons = append(reasons, fmt.Sprintf("method %s was added", name))
+			}
+		}
+
+		for _, name := range sortedKeys(c.Before.Members) {
+			signature, ok := c.After.Members[name]
+			if !ok {
+				reasons = append(reasons, fmt.Sprintf("method %s was removed", name))
+			} else if signature != c.Before.Members[name] {
+				reasons = append(reasons, fmt.Sprintf("method %s changed signature", name))
+			}
+		}
+	default:
+		if c.Before.Shape != c.After.Shape {
+			reasons = append(reasons, "signature changed")
+		}
+	}
+
+	return reasons
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+type SemverBump string
+
+const (
+	SemverMajor SemverBump = "major"
+	SemverMinor SemverBump = "minor"
+	SemverPatch SemverBump = "patch"
+)
+
+// Suggests a semantic version bump based on the impact of the changes on the exported API
+func suggestSemverBump(changes []APIChange) SemverBump {
+	bump := SemverPatch
+
+	for _, change := range changes {
+		if change.Decl().Internal {
+			continue
+		}
+
+		if len(change.BreakingReasons()) > 0 {
+			return SemverMajor
+		}
+
+		bump = SemverMinor
+	}
+
+	return bump
+}
+
+// Renders a breaking changes section with a suggested version bump to append to the description.
+// Nothing is rendered when the public API didn't change.
+func generateBreakingChangesSection(changes []APIChange) string {
+	var public, breaking []string
+	for _, change := range changes {
+		if change.Decl().Internal {
+			continue
+		}
+
+		public = append(public, change.Decl().Name)
+		if reasons := change.BreakingReasons(); len(reasons) > 0 {
+			breaking = append(breaking, fmt.Sprintf("- `%s`: %s", change.Decl().Name, strings.Join(reasons, ", ")))
+		}
+	}
+
+	if len(public) == 0 {
+		return ""
+	}
+
+	if len(breaking) == 0 {
+		breaking = []string{"No breaking changes to the exported API."}
+	}
+
+	return fmt.Sprintf("## Breaking changes\n\n%s\n\n**Suggested version bump:** %s", strings.Join(breaking, "\n"), suggestSemverBump(changes))
+}
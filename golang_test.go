@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportedAPIChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		breaking bool
+		bump     SemverBump
+	}{
+		{
+			"renamed parameter",
+			"package lib\n\nfunc Get(key string) string { return key }\n",
+			"package lib\n\nfunc Get(name string) string { return name }\n",
+			false,
+			SemverMinor,
+		},
+		{
+			"changed parameter type",
+			"package lib\n\nfunc Get(key string) string { return key }\n",
+			"package lib\n\nfunc Get(key int) string { return \"\" }\n",
+			true,
+			SemverMajor,
+		},
+		{
+			"tightened function constraint",
+			"package lib\n\nfunc Index[T any](values []T, value T) int { return 0 }\n",
+			"package lib\n\nfunc Index[T comparable](values []T, value T) int { return 0 }\n",
+			true,
+			SemverMajor,
+		},
+		{
+			"tightened type constraint",
+			"package lib\n\ntype Set[T any] struct{ Values []T }\n",
+			"package lib\n\ntype Set[T comparable] struct{ Values []T }\n",
+			true,
+			SemverMajor,
+		},
+		{
+			"added struct field",
+			"package lib\n\ntype Options struct{ Name string }\n",
+			"package lib\n\ntype Options struct {\n\tName string\n\tAge  int\n}\n",
+			false,
+			SemverMinor,
+		},
+		{
+			"removed struct field",
+			"package lib\n\ntype Options struct {\n\tName string\n\tAge  int\n}\n",
+			"package lib\n\ntype Options struct{ Name string }\n",
+			true,
+			SemverMajor,
+		},
+		{
+			"added interface method",
+			"package lib\n\ntype Store interface{ Get(key string) string }\n",
+			"package lib\n\ntype Store interface {\n\tGet(key string) string\n\tSet(key string, value string)\n}\n",
+			true,
+			SemverMajor,
+		},
+		{
+			"removed function",
+			"package lib\n\nfunc Get() {}\n\nfunc Drop() {}\n",
+			"package lib\n\nfunc Get() {}\n",
+			true,
+			SemverMajor,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before, after := PackageAPI{}, PackageAPI{}
+			if err := extractExportedAPI("lib.go", test.before, "lib", before); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := extractExportedAPI("lib.go", test.after, "lib", after); err != nil {
+				t.Fatal(err)
+			}
+
+			changes := diffExportedAPI(before, after)
+			if len(changes) != 1 {
+				t.Fatalf("expected a single change, got %v", changes)
+			}
+
+			if reasons := changes[0].BreakingReasons(); (len(reasons) > 0) != test.breaking {
+				t.Errorf("expected breaking to be %v, got reasons %v", test.breaking, reasons)
+			}
+
+			if bump := suggestSemverBump(changes); bump != test.bump {
+				t.Errorf("expected a %s bump, got %s", test.bump, bump)
+			}
+		})
+	}
+}
+
+func TestExportedAPIChangesOfRenamedFile(t *testing.T) {
+	dir := setupFixtureRepository(t)
+	writeFile(t, dir, "lib.go", "package lib\n\nfunc Get() string { return \"\" }\n\nfunc Drop() {}\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add lib")
+	writeFile(t, dir, "lib.go", "package lib\n\nfunc Get() string { return \"\" }\n")
+	runGit(t, dir, "mv", "lib.go", "api.go")
+	runGit(t, dir, "commit", "--quiet", "-am", "Rename lib and drop Drop")
+
+	for name, repo := range openBackends(t, dir) {
+		changes, err := getExportedAPIChanges(repo, "HEAD~1", "HEAD")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(changes) != 1 || changes[0].Kind != APIChangeRemoved || !strings.Contains(changes[0].String(), "Drop") {
+			t.Errorf("%s: expected only Drop to be removed, got %v", name, changes)
+		}
+
+		if bump := suggestSemverBump(changes); bump != SemverMajor {
+			t.Errorf("%s: expected a major bump, got %s", name, bump)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/log"
+	"github.com/google/go-github/v61/github"
 	"github.com/segersniels/config"
 	updater "github.com/segersniels/updater"
 	"github.com/urfave/cli/v2"
@@ -20,10 +21,24 @@ var (
 )
 
 type Config struct {
-	Model       SupportedModel `json:"model"`
-	Prompt      string         `json:"prompt"`
-	Template    string         `json:"template"`
-	PrettyPrint bool           `json:"pretty_print"`
+	Model            SupportedModel `json:"model"`
+	Prompt           string         `json:"prompt"`
+	Template         string         `json:"template"`
+	PrettyPrint      bool           `json:"pretty_print"`
+	Filters          []string       `json:"filters"`
+	FilesChanged     bool           `json:"files_changed"`
+	FunctionContext  bool           `json:"function_context"`
+	Backend          string         `json:"backend"`
+	BaseRemote       string         `json:"base_remote"`
+	HeadRemote       string         `json:"head_remote"`
+	TitleFormat      string         `json:"title_format"`
+	Reviewers        []string       `json:"reviewers"`
+	TeamReviewers    []string       `json:"team_reviewers"`
+	Assignees        []string       `json:"assignees"`
+	Labels           []string       `json:"labels"`
+	Milestone        string         `json:"milestone"`
+	CodeOwners       string         `json:"code_owners"`
+	ReviewersSection bool           `json:"reviewers_section"`
 }
 
 var CONFIG = config.NewConfig("propr", Config{
@@ -31,6 +46,8 @@ var CONFIG = config.NewConfig("propr", Config{
 	Prompt:      SYSTEM_MESSAGE,
 	Template:    "# Description",
 	PrettyPrint: true,
+	Backend:     BackendGit,
+	CodeOwners:  CodeOwnersSuggest,
 })
 
 func printMarkdown(content string, pretty bool) error {
@@ -57,6 +74,130 @@ func printMarkdown(content string, pretty bool) error {
 	return nil
 }
 
+// Regenerates the description of an existing pull request and updates it after showing what changes
+func updatePullRequest(propr *Propr, pr *github.PullRequest, pretty bool, editTitle bool) error {
+	replaceUnmarked, err := askReplaceUnmarked(pr)
+	if err != nil {
+		return nil
+	}
+
+	var description string
+	for {
+		// Describe the pull request against the branch it actually targets
+		response, err := propr.Generate(pr.GetBase().GetRef())
+		if err != nil {
+			return err
+		}
+
+		// Only the generated block is replaced so edits made on GitHub survive
+		body, err := replaceGenerated(pr.GetBody(), response, replaceUnmarked)
+		if err != nil {
+			return err
+		}
+
+		diff, err := diffDescriptions(pr.GetBody(), body, pretty)
+		if err != nil {
+			return err
+		}
+
+		if diff == "" {
+			fmt.Println("The description is already up to date")
+			if !editTitle {
+				return nil
+			}
+
+			description = body
+			break
+		}
+
+		fmt.Println(diff)
+
+		var confirmation bool
+		err = huh.NewConfirm().Title(fmt.Sprintf("Do you want to update the description of #%d?", pr.GetNumber())).Value(&confirmation).Run()
+		if err != nil {
+			return nil
+		}
+
+		if confirmation {
+			description = body
+			break
+		}
+	}
+
+	var title string
+	if editTitle {
+		format, err := parseTitleFormat(CONFIG.Data.TitleFormat)
+		if err != nil {
+			return err
+		}
+
+		title, err = inputTitle(pr.GetTitle(), format)
+		if err != nil {
+			return nil
+		}
+	}
+
+	return propr.Update(pr, title, description)
+}
+
+const (
+	UnmarkedDescriptionReplace = "replace"
+	UnmarkedDescriptionAppend  = "append"
+)
+
+// Without markers there is no telling which part of the description was generated, so ask whether it can go as a whole
+func askReplaceUnmarked(pr *github.PullRequest) (bool, error) {
+	if !hasUnmarkedContent(pr.GetBody()) {
+		return false, nil
+	}
+
+	var action string
+	err := huh.NewSelect[string]().
+		Title(fmt.Sprintf("The description of #%d wasn't marked as generated by propr, what do you want to do with it?", pr.GetNumber())).
+		Options(
+			huh.NewOption("Replace the whole description", UnmarkedDescriptionReplace),
+			huh.NewOption("Keep it and add the generated description below it", UnmarkedDescriptionAppend),
+		).
+		Value(&action).
+		Run()
+
+	return action == UnmarkedDescriptionReplace, err
+}
+
+const (
+	ExistingPullRequestUpdate = "update"
+	ExistingPullRequestOpen   = "open"
+	ExistingPullRequestAbort  = "abort"
+)
+
+// GitHub only allows a single open pull request per branch, so offer to continue with the existing one instead
+func handleExistingPullRequest(propr *Propr, pr *github.PullRequest) error {
+	fmt.Printf("A pull request for this branch already exists at %s\n", pr.GetHTMLURL())
+
+	var action string
+	err := huh.NewSelect[string]().
+		Title(fmt.Sprintf("What do you want to do with #%d?", pr.GetNumber())).
+		Options(
+			huh.NewOption("Update its title and description", ExistingPullRequestUpdate),
+			huh.NewOption("Open it in the browser", ExistingPullRequestOpen),
+			huh.NewOption("Abort", ExistingPullRequestAbort),
+		).
+		Value(&action).
+		Run()
+	if err != nil {
+		return nil
+	}
+
+	switch action {
+	case ExistingPullRequestUpdate:
+		return updatePullRequest(propr, pr, CONFIG.Data.PrettyPrint, true)
+	case ExistingPullRequestOpen:
+		return openURL(pr.GetHTMLURL())
+	default:
+		return nil
+	}
+}
+
 func main() {
 	upd := updater.NewUpdater(AppName, AppVersion, "segersniels")
 	err := upd.CheckIfNewVersionIsAvailable()
@@ -73,6 +214,12 @@ func main() {
 		Name:    AppName,
 		Usage:   "Generate your PRs from the command line with AI",
 		Version: AppVersion,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "C",
+				Usage: "Run as if propr was started in `PATH` instead of the current directory",
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "create",
@@ -96,6 +243,46 @@ func main() {
 						Name:  "draft",
 						Usage: "Create a draft PR",
 					},
+					&cli.BoolFlag{
+						Name:  "push",
+						Usage: "Push the branch without asking when it isn't up to date with the remote",
+					},
+					&cli.StringSliceFlag{
+						Name:  "reviewer",
+						Usage: "Request a review from this user, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "team-reviewer",
+						Usage: "Request a review from this team, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "assignee",
+						Usage: "Assign this user, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "Add this label, can be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "milestone",
+						Usage: "Add the PR to the milestone with this title or number",
+					},
+					&cli.BoolFlag{
+						Name:  "select",
+						Usage: "Select reviewers, assignees and labels from a list",
+					},
+					&cli.BoolFlag{
+						Name:  "no-redact",
+						Usage: "Don't redact potential secrets from the diff",
+					},
+					&cli.BoolFlag{
+						Name:  "function-context",
+						Usage: "Expand every change to the function it belongs to",
+					},
+					&cli.BoolFlag{
+						Name:  "two-dot",
+						Usage: "Diff directly against the target branch instead of its merge base",
+					},
 				},
 				Action: func(ctx *cli.Context) error {
 					draft := ctx.Bool("draft")
@@ -105,6 +292,18 @@ func main() {
 						log.Fatal(err)
 					}
 
+					err = propr.EnsurePushed(ctx.Bool("push"))
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					existing, err := propr.FindPullRequest()
+					if err != nil {
+						log.Fatal(err)
+					} else if existing != nil {
+						return handleExistingPullRequest(propr, existing)
+					}
+
 					if ctx.Bool("empty") {
 						return propr.Create("", "", draft)
 					}
@@ -136,6 +335,57 @@ func main() {
 					return propr.Create("", description, draft)
 				},
 			},
+			{
+				Name:  "update",
+				Usage: "Regenerates the description of the open PR of the current branch",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "model",
+						Aliases: []string{"m"},
+						Usage:   "Select a model from a list",
+					},
+					&cli.BoolFlag{
+						Name:  "plain",
+						Usage: "Output the changes to the description without any formatting",
+					},
+					&cli.BoolFlag{
+						Name:  "push",
+						Usage: "Push the branch without asking when it isn't up to date with the remote",
+					},
+					&cli.BoolFlag{
+						Name:  "no-redact",
+						Usage: "Don't redact potential secrets from the diff",
+					},
+					&cli.BoolFlag{
+						Name:  "function-context",
+						Usage: "Expand every change to the function it belongs to",
+					},
+					&cli.BoolFlag{
+						Name:  "two-dot",
+						Usage: "Diff directly against the target branch instead of its merge base",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					propr, err := NewPropr(ctx)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					err = propr.EnsurePushed(ctx.Bool("push"))
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					pr, err := propr.FindPullRequest()
+					if err != nil {
+						log.Fatal(err)
+					} else if pr == nil {
+						log.Fatal("No open pull request found for the current branch, create one with `propr create`")
+					}
+
+					return updatePullRequest(propr, pr, CONFIG.Data.PrettyPrint && !ctx.Bool("plain"), false)
+				},
+			},
 			{
 				Name:  "generate",
 				Usage: "Generates a PR description and outputs it",
@@ -144,6 +394,26 @@ func main() {
 						Name:  "plain",
 						Usage: "Output the generated description without any formatting",
 					},
+					&cli.BoolFlag{
+						Name:  "staged",
+						Usage: "Describe the staged changes instead of the branch",
+					},
+					&cli.BoolFlag{
+						Name:  "working-tree",
+						Usage: "Describe all uncommitted changes instead of the branch",
+					},
+					&cli.StringFlag{
+						Name:  "base",
+						Usage: "Compare against this branch, tag or commit instead of the target branch",
+					},
+					&cli.StringFlag{
+						Name:  "head",
+						Usage: "Describe this branch, tag or commit instead of the current branch",
+					},
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "Describe all commits after this commit",
+					},
 					&cli.BoolFlag{
 						Name:    "branch",
 						Aliases: []string{"b"},
@@ -154,6 +424,18 @@ func main() {
 						Aliases: []string{"m"},
 						Usage:   "Select a model from a list",
 					},
+					&cli.BoolFlag{
+						Name:  "no-redact",
+						Usage: "Don't redact potential secrets from the diff",
+					},
+					&cli.BoolFlag{
+						Name:  "function-context",
+						Usage: "Expand every change to the function it belongs to",
+					},
+					&cli.BoolFlag{
+						Name:  "two-dot",
+						Usage: "Diff directly against the target branch instead of its merge base",
+					},
 				},
 				Action: func(ctx *cli.Context) error {
 					propr, err := NewPropr(ctx)
@@ -221,6 +503,28 @@ func main() {
 										Title("Pretty Print").
 										Description("Do you want to pretty print the generated output?").
 										Value(&CONFIG.Data.PrettyPrint),
+									huh.NewConfirm().
+										Title("Files Changed").
+										Description("Do you want to append a collapsible list of the changed files to the description?").
+										Value(&CONFIG.Data.FilesChanged),
+									huh.NewConfirm().
+										Title("Function Context").
+										Description("Do you want to include the enclosing function of every change in the diff?").
+										Value(&CONFIG.Data.FunctionContext),
+									huh.NewConfirm().
+										Title("Reviewers Section").
+										Description("Do you want to append the code owners of the changed files to the description?").
+										Value(&CONFIG.Data.ReviewersSection),
+								),
+								huh.NewGroup(
+									huh.NewInput().
+										Title("Title Format").
+										Description("Leave empty to allow any title, or use `conventional`, `jira` or a regular expression").
+										Value(&CONFIG.Data.TitleFormat).
+										Validate(func(format string) error {
+											_, err := parseTitleFormat(format)
+											return err
+										}),
 								),
 							)
 
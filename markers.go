@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Comments that enclose the generated part of a description, everything around them is written by people and left alone
+const (
+	GeneratedStartMarker = "<!-- propr:start -->"
+	GeneratedEndMarker   = "<!-- propr:end -->"
+)
+
+func wrapGenerated(description string) string {
+	return GeneratedStartMarker + "\n" + strings.TrimSpace(description) + "\n" + GeneratedEndMarker
+}
+
+// Descriptions written before the markers were introduced, by hand or by propr itself, don't have them
+func hasUnmarkedContent(body string) bool {
+	return strings.TrimSpace(body) != "" && !strings.Contains(body, GeneratedStartMarker)
+}
+
+// Replaces the generated block of the body and keeps everything outside of the markers byte for byte.
+// Bodies without markers are replaced as a whole or get the generated block appended, depending on replaceUnmarked.
+func replaceGenerated(body string, description string, replaceUnmarked bool) (string, error) {
+	start := strings.Index(body, GeneratedStartMarker)
+	if start == -1 {
+		if strings.TrimSpace(body) == "" || replaceUnmarked {
+			return wrapGenerated(description), nil
+		}
+
+		return body + "\n\n" + wrapGenerated(description), nil
+	}
+
+	end := strings.Index(body[start:], GeneratedEndMarker)
+	if end == -1 {
+		return "", fmt.Errorf("the description has a %s marker without a matching %s marker", GeneratedStartMarker, GeneratedEndMarker)
+	}
+
+	end += start + len(GeneratedEndMarker)
+
+	return body[:start] + wrapGenerated(description) + body[end:], nil
+}
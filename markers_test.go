@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestReplaceGenerated(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		replaceUnmarked bool
+		expected        string
+	}{
+		{"empty body", "", false, wrapGenerated("new")},
+		{"markers", "Intro\n\n" + wrapGenerated("old") + "\n\n- [x] Checked", false, "Intro\n\n" + wrapGenerated("new") + "\n\n- [x] Checked"},
+		{"markers ignore replace", "Intro\n" + wrapGenerated("old"), true, "Intro\n" + wrapGenerated("new")},
+		{"unmarked appended", "Written before the markers", false, "Written before the markers\n\n" + wrapGenerated("new")},
+		{"unmarked replaced", "Written before the markers", true, wrapGenerated("new")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := replaceGenerated(test.body, "new", test.replaceUnmarked)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if body != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, body)
+			}
+		})
+	}
+
+	if _, err := replaceGenerated(GeneratedStartMarker+"\nold", "new", false); err == nil {
+		t.Error("expected an error for a start marker without an end marker")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	"github.com/google/go-github/v61/github"
+	"github.com/urfave/cli/v2"
+)
+
+// Everything besides the title and description that is set on a pull request after it is created
+type PullRequestMetadata struct {
+	Reviewers     []string
+	TeamReviewers []string
+	Assignees     []string
+	Labels        []string
+	// Title or number of the milestone
+	Milestone string
+}
+
+// Takes the metadata from the flags, falling back to the configured defaults for anything that isn't passed
+func NewPullRequestMetadata(ctx *cli.Context) PullRequestMetadata {
+	metadata := PullRequestMetadata{
+		Reviewers:     CONFIG.Data.Reviewers,
+		TeamReviewers: CONFIG.Data.TeamReviewers,
+		Assignees:     CONFIG.Data.Assignees,
+		Labels:        CONFIG.Data.Labels,
+		Milestone:     CONFIG.Data.Milestone,
+	}
+
+	if ctx == nil {
+		return metadata
+	}
+
+	for flag, value := range map[string]*[]string{
+		"reviewer":      &metadata.Reviewers,
+		"team-reviewer": &metadata.TeamReviewers,
+		"assignee":      &metadata.Assignees,
+		"label":         &metadata.Labels,
+	} {
+		if ctx.IsSet(flag) {
+			*value = ctx.StringSlice(flag)
+		}
+	}
+
+	if ctx.IsSet("milestone") {
+		metadata.Milestone = ctx.String("milestone")
+	}
+
+	return metadata
+}
+
+func (m PullRequestMetadata) isEmpty() bool {
+	return len(m.Reviewers) == 0 && len(m.TeamReviewers) == 0 && len(m.Assignees) == 0 && len(m.Labels) == 0 && m.Milestone == ""
+}
+
+func (g *GitHub) collaborators() ([]string, error) {
+	var logins []string
+
+	opts := &github.ListCollaboratorsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		users, response, err := g.client.Repositories.ListCollaborators(context.Background(), g.repo.GetOwner().GetLogin(), g.repo.GetName(), opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range users {
+			logins = append(logins, user.GetLogin())
+		}
+
+		if response.NextPage == 0 {
+			return logins, nil
+		}
+
+		opts.Page = response.NextPage
+	}
+}
+
+func (g *GitHub) labels() ([]string, error) {
+	var names []string
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		labels, response, err := g.client.Issues.ListLabels(context.Background(), g.repo.GetOwner().GetLogin(), g.repo.GetName(), opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, label := range labels {
+			names = append(names, label.GetName())
+		}
+
+		if response.NextPage == 0 {
+			return names, nil
+		}
+
+		opts.Page = response.NextPage
+	}
+}
+
+// Options for a multi select, values that were already chosen are kept even when the repository doesn't list them
+func metadataOptions(available []string, selected []string) []huh.Option[string] {
+	values := slices.Clone(available)
+	for _, value := range selected {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+
+	return huh.NewOptions(values...)
+}
+
+// Lets the user pick reviewers, assignees and labels from the ones known to the repository
+func (g *GitHub) selectMetadata(metadata *PullRequestMetadata) error {
+	var fields []huh.Field
+
+	collaborators, err := g.collaborators()
+	if err != nil {
+		log.Debug("Failed to list collaborators", "error", err)
+	} else if len(collaborators) > 0 {
+		fields = append(fields,
+			huh.NewMultiSelect[string]().
+				Title("Select reviewers").
+				Options(metadataOptions(collaborators, metadata.Reviewers)...).
+				Value(&metadata.Reviewers).
+				Filterable(true).
+				Height(10),
+			huh.NewMultiSelect[string]().
+				Title("Select assignees").
+				Options(metadataOptions(collaborators, metadata.Assignees)...).
+				Value(&metadata.Assignees).
+				Filterable(true).
+				Height(10),
+		)
+	}
+
+	labels, err := g.labels()
+	if err != nil {
+		log.Debug("Failed to list labels", "error", err)
+	} else if len(labels) > 0 {
+		fields = append(fields,
+			huh.NewMultiSelect[string]().
+				Title("Select labels").
+				Options(metadataOptions(labels, metadata.Labels)...).
+				Value(&metadata.Labels).
+				Filterable(true).
+				Height(10),
+		)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var groups []*huh.Group
+	for _, field := range fields {
+		groups = append(groups, huh.NewGroup(field))
+	}
+
+	return huh.NewForm(groups...).Run()
+}
+
+// Resolves a milestone by its number or title, only open milestones can be found by title
+func (g *GitHub) milestone(milestone string) (int, error) {
+	if number, err := strconv.Atoi(milestone); err == nil {
+		if _, _, err := g.client.Issues.GetMilestone(context.Background(), g.repo.GetOwner().GetLogin(), g.repo.GetName(), number); err != nil {
+			return 0, fmt.Errorf("milestone %d not found: %w", number, err)
+		}
+
+		return number, nil
+	}
+
+	opts := &github.MilestoneListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, response, err := g.client.Issues.ListMilestones(context.Background(), g.repo.GetOwner().GetLogin(), g.repo.GetName(), opts)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, m := range milestones {
+			if strings.EqualFold(m.GetTitle(), milestone) {
+				return m.GetNumber(), nil
+			}
+		}
+
+		if response.NextPage == 0 {
+			return 0, fmt.Errorf("no open milestone named %q found", milestone)
+		}
+
+		opts.Page = response.NextPage
+	}
+}
+
+// GitHub only accepts reviewers, assignees, labels and the milestone once the pull request exists.
+// The milestone has to be resolved beforehand, zero means none. Whatever can be applied is, the failures are reported together.
+func (g *GitHub) applyMetadata(pr *github.PullRequest, metadata PullRequestMetadata, milestone int) error {
+	ctx := context.Background()
+	owner, name := g.repo.GetOwner().GetLogin(), g.repo.GetName()
+
+	var errs []error
+
+	// Authors can't review their own pull request
+	reviewers := slices.DeleteFunc(slices.Clone(metadata.Reviewers), func(reviewer string) bool {
+		return strings.EqualFold(reviewer, pr.GetUser().GetLogin())
+	})
+
+	if len(reviewers) > 0 || len(metadata.TeamReviewers) > 0 {
+		log.Debug("Requesting reviewers", "reviewers", reviewers, "teams", metadata.TeamReviewers)
+		_, _, err := g.client.PullRequests.RequestReviewers(ctx, owner, name, pr.GetNumber(), github.ReviewersRequest{
+			Reviewers:     reviewers,
+			TeamReviewers: metadata.TeamReviewers,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to request reviewers: %w", err))
+		}
+	}
+
+	if len(metadata.Assignees) == 0 && len(metadata.Labels) == 0 && milestone == 0 {
+		return errors.Join(errs...)
+	}
+
+	// Pull requests are issues as far as assignees, labels and milestones are concerned
+	request := &github.IssueRequest{}
+	if len(metadata.Assignees) > 0 {
+		request.Assignees = &metadata.Assignees
+	}
+
+	if len(metadata.Labels) > 0 {
+		request.Labels = &metadata.Labels
+	}
+
+	if milestone != 0 {
+		request.Milestone = github.Int(milestone)
+	}
+
+	log.Debug("Updating pull request metadata", "assignees", metadata.Assignees, "labels", metadata.Labels, "milestone", milestone)
+	if _, _, err := g.client.Issues.Edit(ctx, owner, name, pr.GetNumber(), request); err != nil {
+		errs = append(errs, fmt.Errorf("failed to set assignees, labels or milestone: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
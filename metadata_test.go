@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// Serves the given handlers as the GitHub API of the `octo/repo` repository
+func newTestGitHub(t *testing.T, handlers map[string]http.HandlerFunc) *GitHub {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for pattern, handler := range handlers {
+		mux.HandleFunc(pattern, handler)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	return &GitHub{
+		client: client,
+		repo:   &github.Repository{Owner: &github.User{Login: github.String("octo")}, Name: github.String("repo")},
+	}
+}
+
+func TestMilestone(t *testing.T) {
+	gh := newTestGitHub(t, map[string]http.HandlerFunc{
+		"GET /repos/octo/repo/milestones": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]*github.Milestone{{Number: github.Int(3), Title: github.String("v1.2")}})
+		},
+		"GET /repos/octo/repo/milestones/3": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(&github.Milestone{Number: github.Int(3)})
+		},
+	})
+
+	for milestone, expected := range map[string]int{"V1.2": 3, "3": 3, "v1.3": 0, "4": 0} {
+		number, err := gh.milestone(milestone)
+		if (err != nil) != (expected == 0) || number != expected {
+			t.Errorf("milestone(%q) = %d, %v, expected %d", milestone, number, err, expected)
+		}
+	}
+}
+
+func TestApplyMetadataContinuesAfterFailure(t *testing.T) {
+	var edited map[string]any
+	gh := newTestGitHub(t, map[string]http.HandlerFunc{
+		"POST /repos/octo/repo/pulls/1/requested_reviewers": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message": "Reviews may only be requested from collaborators"}`, http.StatusUnprocessableEntity)
+		},
+		"PATCH /repos/octo/repo/issues/1": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&edited)
+			json.NewEncoder(w).Encode(&github.Issue{Number: github.Int(1)})
+		},
+	})
+
+	pr := &github.PullRequest{Number: github.Int(1), User: &github.User{Login: github.String("author")}}
+	err := gh.applyMetadata(pr, PullRequestMetadata{Reviewers: []string{"stranger"}, Labels: []string{"bug"}}, 3)
+	if err == nil || !strings.Contains(err.Error(), "failed to request reviewers") {
+		t.Errorf("expected the failed review request to be reported, got %v", err)
+	}
+
+	if edited == nil || edited["milestone"] != float64(3) {
+		t.Errorf("expected the labels and milestone to be set anyway, got %v", edited)
+	}
+}
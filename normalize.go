@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+var _ DiffFilter = (*NotebookFilter)(nil)
+
+type NotebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+}
+
+type Notebook struct {
+	Cells []NotebookCell `json:"cells"`
+}
+
+// Reduces a Jupyter notebook to the sources of its cells, dropping outputs and metadata
+func normalizeNotebook(content string) (string, error) {
+	if content == "" {
+		return "", nil
+	}
+
+	var notebook Notebook
+	if err := json.Unmarshal([]byte(content), &notebook); err != nil {
+		return "", err
+	}
+
+	var cells []string
+	for i, cell := range notebook.Cells {
+		// The source of a cell is either a single string or a list of lines
+		var source string
+		var lines []string
+		if err := json.Unmarshal(cell.Source, &lines); err == nil {
+			source = strings.Join(lines, "")
+		} else if err := json.Unmarshal(cell.Source, &source); err != nil {
+			return "", err
+		}
+
+		cells = append(cells, fmt.Sprintf("# %%%% [%s] cell %d\n%s", cell.CellType, i+1, strings.TrimRight(source, "\n")))
+	}
+
+	return strings.Join(cells, "\n\n") + "\n", nil
+}
+
+// Replaces the JSON diff of notebooks with a diff of only the cell sources
+type NotebookFilter struct {
+	diffRange DiffRange
+}
+
+func (f *NotebookFilter) Name() string {
+	return FilterNotebook
+}
+
+func (f *NotebookFilter) normalize(chunk string) (string, error) {
+	header := strings.Split(chunk, "\n")[0]
+	path := getChunkPath(chunk)
+
+	before, err := f.diffRange.Repo.FileAtRevision(f.diffRange.Base, path)
+	if err != nil {
+		return "", err
+	}
+
+	after, err := f.diffRange.Repo.FileAtRevision(f.diffRange.Head, path)
+	if err != nil {
+		return "", err
+	}
+
+	normalizedBefore, err := normalizeNotebook(before)
+	if err != nil {
+		return "", err
+	}
+
+	normalizedAfter, err := normalizeNotebook(after)
+	if err != nil {
+		return "", err
+	}
+
+	diff, err := diffContents(normalizedBefore, normalizedAfter)
+	if err != nil {
+		return "", err
+	}
+
+	// Only keep the hunks, the headers refer to the temporary files
+	index := strings.Index(diff, "\n@@")
+	if index == -1 {
+		return header + "\nOnly outputs or metadata of the notebook changed", nil
+	}
+
+	return fmt.Sprintf("%s\n--- a/%s\n+++ b/%s%s", header, path, path, strings.TrimRight(diff[index:], "\n")), nil
+}
+
+func (f *NotebookFilter) Filter(chunks []string) ([]string, []string) {
+	var removed []string
+
+	result := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = chunk
+
+		if !strings.HasSuffix(getChunkPath(chunk), ".ipynb") {
+			continue
+		}
+
+		normalized, err := f.normalize(chunk)
+		if err != nil {
+			log.Debug("Failed to normalize notebook", "file", getChunkPath(chunk), "error", err)
+			continue
+		}
+
+		result[i] = normalized
+		removed = append(removed, "outputs and metadata of "+getChunkPath(chunk))
+	}
+
+	return result, removed
+}
+
+var _ DiffFilter = (*MinifiedFilter)(nil)
+
+// Changed lines this long are not written by a human
+const (
+	MINIFIED_LINE_LENGTH         = 1000
+	MINIFIED_AVERAGE_LINE_LENGTH = 250
+)
+
+var minifiedPathRegex = regexp.MustCompile(`(\.min\.(js|css|mjs)|\.bundle\.js|\.js\.map|\.css\.map)$`)
+
+func isMinified(chunk string) bool {
+	if minifiedPathRegex.MatchString(getChunkPath(chunk)) {
+		return true
+	}
+
+	count, total := 0, 0
+	for _, line := range strings.Split(chunk, "\n") {
+		if isDiffHeader(line) || !(strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) {
+			continue
+		}
+
+		if len(line) > MINIFIED_LINE_LENGTH {
+			return true
+		}
+
+		count++
+		total += len(line)
+	}
+
+	return count > 0 && total/count > MINIFIED_AVERAGE_LINE_LENGTH
+}
+
+// Collapses minified and bundled files to a single line note
+type MinifiedFilter struct{}
+
+func (f *MinifiedFilter) Name() string {
+	return FilterMinified
+}
+
+func (f *MinifiedFilter) Filter(chunks []string) ([]string, []string) {
+	var removed []string
+
+	result := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = chunk
+
+		if !isMinified(chunk) {
+			continue
+		}
+
+		result[i] = strings.Split(chunk, "\n")[0] + "\nMinified or bundled file changed, contents omitted"
+		removed = append(removed, getChunkPath(chunk))
+	}
+
+	return result, removed
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"strings"
-	"sync"
 
 	"github.com/charmbracelet/log"
 )
@@ -42,52 +41,60 @@ func splitDiffIntoChunks(diff string) []string {
 	return split
 }
 
-func removeLockFiles(chunks []string) []string {
-	var wg sync.WaitGroup
-
-	filtered := make(chan string)
-
-	for _, chunk := range chunks {
-		wg.Add(1)
+// Extracts the path of the changed file from the chunk header (`a/path b/path`)
+func getChunkPath(chunk string) string {
+	header := strings.Split(chunk, "\n")[0]
+	if index := strings.LastIndex(header, " b/"); index != -1 {
+		return header[index+3:]
+	}
 
-		go func(chunk string) {
-			defer wg.Done()
-			shouldIgnore := false
-			header := strings.Split(chunk, "\n")[0]
+	return strings.TrimPrefix(header, "a/")
+}
 
-			// Check if the first line contains any of the files to ignore
-			for _, file := range FILES_TO_IGNORE {
-				if strings.Contains(header, file) {
-					log.Debug("Ignoring", "file", file)
-					shouldIgnore = true
-				}
-			}
+// The amount of extra characters the function context is allowed to add to the diff
+const FUNCTION_CONTEXT_BUDGET = 50_000
 
-			if !shouldIgnore {
-				log.Debug("Adding", "header", header)
-				filtered <- chunk
-			}
-		}(chunk)
+// Swaps chunks of the diff for their counterpart with function context for as long as the budget allows.
+// Files that would blow the budget keep their regular hunks.
+func expandFunctionContext(diff string, expanded string) string {
+	expandedChunks := map[string]string{}
+	for _, chunk := range splitDiffIntoChunks(expanded) {
+		expandedChunks[getChunkPath(chunk)] = chunk
 	}
 
-	go func() {
-		wg.Wait()
-		close(filtered)
-	}()
+	chunks := splitDiffIntoChunks(diff)
+	if len(chunks) == 0 {
+		return diff
+	}
 
-	var result []string
-	for chunk := range filtered {
-		result = append(result, chunk)
+	budget := FUNCTION_CONTEXT_BUDGET
+	for i, chunk := range chunks {
+		candidate, ok := expandedChunks[getChunkPath(chunk)]
+		if !ok {
+			continue
+		}
+
+		extra := len(candidate) - len(chunk)
+		if extra > budget {
+			log.Debug("Function context exceeds the budget", "file", getChunkPath(chunk), "extra", extra, "budget", budget)
+			continue
+		}
+
+		budget -= extra
+		chunks[i] = candidate
 	}
 
-	return result
+	return "diff --git " + strings.Join(chunks, "\ndiff --git ")
 }
 
-// Split the diff in chunks and remove any lock files to save on tokens
-func prepareDiff(diff string) string {
-	chunks := splitDiffIntoChunks(diff)
+// Split the diff in chunks and run them through the configured filters to save on tokens
+func prepareDiff(diff string, filters []string, diffRange DiffRange) (string, error) {
+	pipeline, err := NewDiffPipeline(filters, diffRange)
+	if err != nil {
+		return "", err
+	}
 
-	return strings.Join(removeLockFiles(chunks), "\n")
+	return strings.Join(pipeline.Run(splitDiffIntoChunks(diff)), "\n"), nil
 }
 
 func generateSystemMessageForDiff(systemMessage string, template string) string {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -52,31 +53,31 @@ type GitHub struct {
 	repo   *github.Repository
 }
 
-func NewGitHub() *GitHub {
+func NewGitHub(local Repository, remote string) (*GitHub, error) {
 	token := os.Getenv("GITHUB_TOKEN")
 	if token == "" {
-		log.Fatal("GITHUB_TOKEN is not set")
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set")
 	}
 
 	gh := github.NewClient(nil).WithAuthToken(token)
-	info, err := getRepositoryInformation()
+	info, err := getRepositoryInformation(local, remote)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	repo, _, err := gh.Repositories.Get(context.Background(), info.Owner, info.Name)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	return &GitHub{
 		gh,
 		repo,
-	}
+	}, nil
 }
 
-func selectBranch() (string, error) {
-	branches, err := getAllBranches()
+func selectBranch(repo Repository) (string, error) {
+	branches, err := repo.Branches()
 	if err != nil {
 		return "", err
 	}
@@ -85,7 +86,7 @@ func selectBranch() (string, error) {
 		return "", fmt.Errorf("no branches found")
 	}
 
-	currentBranch, err := getCurrentBranch()
+	currentBranch, err := repo.CurrentBranch()
 	if err != nil {
 		return "", err
 	}
@@ -142,15 +143,65 @@ func selectModel() (SupportedModel, error) {
 }
 
 type Propr struct {
-	model  SupportedModel
-	target string
+	repo            Repository
+	baseRemote      string
+	headRemote      string
+	model           SupportedModel
+	target          string
+	localTarget     bool
+	redact          bool
+	functionContext bool
+	twoDot          bool
+	base            string
+	head            string
+	layout          RepositoryLayout
+	gh              *GitHub
+	metadata        PullRequestMetadata
+	selectMetadata  bool
+	owners          []OwnedArea
 }
 
 func NewPropr(ctx *cli.Context) (*Propr, error) {
+	// Run against another repository than the one in the working directory, like `git -C`
+	dir := "."
+	if ctx != nil && ctx.String("C") != "" {
+		dir = ctx.String("C")
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	repo, err := NewRepository(CONFIG.Data.Backend, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := repo.Layout()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Opened repository", "dir", dir, "bare", layout.Bare, "shallow", layout.Shallow, "worktree", layout.IsWorktree())
+
+	baseRemote, headRemote, err := resolveRemotes(repo, CONFIG.Data.BaseRemote, CONFIG.Data.HeadRemote)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Resolved remotes", "base", baseRemote, "head", headRemote)
+
 	// Create a new Propr instance with default configuration
 	propr := &Propr{
-		model:  CONFIG.Data.Model,
-		target: "",
+		repo:            repo,
+		baseRemote:      baseRemote,
+		headRemote:      headRemote,
+		model:           CONFIG.Data.Model,
+		target:          "",
+		redact:          true,
+		functionContext: CONFIG.Data.FunctionContext,
+		layout:          layout,
+		metadata:        NewPullRequestMetadata(ctx),
 	}
 
 	// If no context is provided, just return the default instance
@@ -158,9 +209,33 @@ func NewPropr(ctx *cli.Context) (*Propr, error) {
 		return propr, nil
 	}
 
+	propr.selectMetadata = ctx.Bool("select")
+	propr.redact = !ctx.Bool("no-redact")
+	propr.functionContext = propr.functionContext || ctx.Bool("function-context")
+	propr.twoDot = ctx.Bool("two-dot")
+
+	// Describe uncommitted work instead of the branch
+	switch {
+	case ctx.Bool("staged") && ctx.Bool("working-tree"):
+		return nil, fmt.Errorf("--staged and --working-tree can't be combined")
+	case ctx.Bool("staged"):
+		propr.head = HeadStaged
+	case ctx.Bool("working-tree"):
+		propr.head = HeadWorkingTree
+	}
+
+	if layout.Bare && isUncommitted(propr.head) {
+		return nil, fmt.Errorf("%s is a bare repository, it has no uncommitted changes to describe", dir)
+	}
+
+	// Describe an arbitrary range of commits, like a release between two tags
+	if err := propr.setRange(ctx.String("base"), ctx.String("head"), ctx.String("since")); err != nil {
+		return nil, err
+	}
+
 	// Handle branch selection
 	if ctx.Bool("branch") {
-		selectedBranch, err := selectBranch()
+		selectedBranch, err := selectBranch(propr.repo)
 		if err != nil {
 			return nil, err
 		}
@@ -179,24 +254,172 @@ func NewPropr(ctx *cli.Context) (*Propr, error) {
 	return propr, nil
 }
 
-func (p *Propr) Generate(target string) (string, error) {
-	gh := NewGitHub()
+func (p *Propr) setRange(base string, head string, since string) error {
+	if base != "" && since != "" {
+		return fmt.Errorf("--base and --since can't be combined")
+	}
 
-	// Use the target from the Propr instance if set, otherwise use the provided target or default branch
-	if p.target != "" {
-		target = p.target
-	} else if target == "" {
-		target = gh.repo.GetDefaultBranch()
+	if isUncommitted(p.head) && (base != "" || head != "" || since != "") {
+		return fmt.Errorf("--base, --head and --since can't be combined with uncommitted changes")
+	}
+
+	for _, ref := range []string{base, head, since} {
+		if ref != "" && !p.repo.HasRevision(ref) {
+			return fmt.Errorf("unknown revision %s", ref)
+		}
+	}
+
+	// Everything after the given commit is described as is, without looking for a merge base
+	if since != "" {
+		base = since
+		p.twoDot = true
+	}
+
+	p.base = base
+	if head != "" {
+		p.head = head
+	}
+
+	return nil
+}
+
+// The configured diff filters, falling back to the defaults when none are configured
+func (p *Propr) filters() []string {
+	filters := CONFIG.Data.Filters
+	if len(filters) == 0 {
+		filters = DEFAULT_FILTERS
+	}
+
+	var result []string
+	for _, filter := range filters {
+		if filter == FilterRedact && !p.redact {
+			continue
+		}
+
+		result = append(result, filter)
+	}
+
+	return result
+}
+
+// Name of the current branch, or the commit HEAD points to when it is detached
+func (p *Propr) currentHead() (string, error) {
+	branch, err := p.repo.CurrentBranch()
+	if err != nil || branch != "" {
+		return branch, err
+	}
+
+	log.Debug("HEAD is detached, falling back to the commit it points to")
+	return p.repo.ResolveRevision("HEAD")
+}
+
+// Pull requests can only be opened from a branch
+func (p *Propr) currentBranch() (string, error) {
+	branch, err := p.repo.CurrentBranch()
+	if err != nil {
+		return "", err
 	}
 
+	if branch == "" {
+		return "", fmt.Errorf("HEAD is detached, check out a branch to create a pull request")
+	}
+
+	return branch, nil
+}
+
+// Explains why the remote-tracking branch of the target is missing and offers to fetch it
+func (p *Propr) fetchTarget(target string) error {
+	ref := p.baseRemote + "/" + target
+
+	var reason string
+	switch {
+	case p.layout.Bare:
+		reason = fmt.Sprintf("%s is missing, bare repositories only have remote-tracking branches when they are fetched explicitly", ref)
+	case p.layout.Shallow:
+		reason = fmt.Sprintf("%s is missing, shallow clones usually only fetch the branch they were cloned with", ref)
+	case p.layout.IsWorktree():
+		reason = fmt.Sprintf("%s is missing, worktrees share their remote-tracking branches with the repository in %s which hasn't fetched it", ref, filepath.Dir(p.layout.CommonDir))
+	default:
+		reason = fmt.Sprintf("%s is missing, it hasn't been fetched yet", ref)
+	}
+
+	var fetch bool
+	err := huh.NewConfirm().Title(reason + ", do you want to fetch it?").Value(&fetch).Run()
+	if err != nil {
+		return err
+	}
+
+	if !fetch {
+		return fmt.Errorf("%s, run `git fetch %s %s` first", reason, p.baseRemote, target)
+	}
+
+	log.Debug("Fetching target branch", "remote", p.baseRemote, "branch", target)
+	return p.repo.Fetch(p.baseRemote, target)
+}
+
+func (p *Propr) Generate(target string) (string, error) {
 	// Get the current branch where changes are present
-	current, err := getCurrentBranch()
+	current, err := p.currentHead()
 	if err != nil {
 		return "", err
 	}
 
-	log.Debug("Fetching diff", "target", target, "current", current)
-	diff, err := getDiff(current, target)
+	// Everything needed to generate a description comes from git, GitHub is only needed to open the pull request
+	url, err := repositoryURL(p.repo, p.baseRemote)
+	if err != nil {
+		log.Debug("Failed to determine repository URL", "remote", p.baseRemote, "error", err)
+	}
+
+	var base, head string
+	var stack *Stack
+	if isUncommitted(p.head) {
+		// Uncommitted work is compared to the last commit
+		base, head = "HEAD", p.head
+	} else {
+		head = current
+		if p.head != "" {
+			head = p.head
+			current = p.head
+		}
+
+		if p.base != "" {
+			// An explicit range doesn't involve a target branch, so it doesn't need a remote either
+			base = p.base
+		} else {
+			target, err = p.resolveTarget(target, head)
+			if err != nil {
+				return "", err
+			}
+
+			base = p.baseRemote + "/" + target
+			if (p.layout.Bare || p.localTarget) && !p.repo.HasRevision(base) && p.repo.HasRevision(target) {
+				// Bare repositories usually hold the branches themselves instead of remote-tracking branches,
+				// and a stacked branch can be based on a branch that hasn't been pushed yet
+				base = target
+			} else if !p.repo.HasRevision(base) {
+				if err := p.fetchTarget(target); err != nil {
+					return "", err
+				}
+			}
+
+			stack = p.findStack(target, current)
+		}
+
+		// Diff from where the branch forked off so changes that landed on the target since don't show up reversed
+		if !p.twoDot {
+			mergeBase, err := p.repo.MergeBase(base, head)
+			if err != nil && p.layout.Shallow {
+				return "", fmt.Errorf("failed to determine merge base of %s and %s, the history of this shallow clone probably doesn't go back far enough, run `git fetch --unshallow` first: %w", base, head, err)
+			} else if err != nil {
+				return "", fmt.Errorf("failed to determine merge base of %s and %s: %w", base, head, err)
+			}
+
+			base = mergeBase
+		}
+	}
+
+	log.Debug("Fetching diff", "target", target, "current", current, "base", base, "head", head)
+	diff, err := p.repo.Diff(base, head)
 	if err != nil {
 		return "", err
 	}
@@ -205,22 +428,78 @@ func (p *Propr) Generate(target string) (string, error) {
 		return "", fmt.Errorf("not enough changes found to generate")
 	}
 
+	// Explain intent rather than restate lines by showing the model the whole function that changed
+	if p.functionContext {
+		expanded, err := p.repo.FunctionContextDiff(base, head)
+		if err != nil {
+			return "", err
+		}
+
+		diff = expandFunctionContext(diff, expanded)
+	}
+
+	prepared, err := prepareDiff(diff, p.filters(), DiffRange{Repo: p.repo, Base: base, Head: head})
+	if err != nil {
+		return "", err
+	}
+
+	// Renames, deletions and mode changes are easy to miss in the raw hunks
+	rawStat, err := p.repo.DiffStat(base, head)
+	if err != nil {
+		return "", err
+	}
+
+	stats := parseDiffStat(rawStat)
+
+	// Owners come from the CODEOWNERS of the base, just like GitHub determines them
+	if CONFIG.Data.CodeOwners != CodeOwnersOff || CONFIG.Data.ReviewersSection {
+		files, err := p.repo.ChangedFiles(base, head)
+		if err != nil {
+			return "", err
+		}
+
+		p.owners, err = getCodeOwners(p.repo, base, files)
+		if err != nil {
+			log.Debug("Failed to determine code owners", "error", err)
+		}
+	}
+
+	// Give the model a reliable changelog of the exported API, even when the diff gets truncated
+	apiChanges, err := getExportedAPIChanges(p.repo, base, head)
+	if err != nil {
+		log.Debug("Failed to determine exported API changes", "error", err)
+	}
+
 	var description string
 	err = spinner.New().TitleStyle(lipgloss.NewStyle()).Title("Generating your pull request...").Action(func() {
 		// Set a timeout for the request
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 		defer cancel()
 
-		commits, err := getCommitMessages(current, target)
+		commits, err := p.repo.Log(base, head)
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		var formatted []string
+		for _, commit := range commits {
+			formatted = append(formatted, commit.String())
+		}
+
+		commitMessages := strings.Join(formatted, "\n\n")
+		if commitMessages == "" {
+			commitMessages = "There are no commits, the changes haven't been committed yet"
+		}
+
+		if p.redact {
+			commitMessages, _ = redactSecrets(commitMessages)
+		}
+
 		// We provide as much context as possible to get the best possible description
 		messages := []Message{
 			{
 				Role:    MessageRoleUser,
-				Content: gh.repo.GetURL(),
+				Content: url,
 			},
 			{
 				Role:    MessageRoleAssistant,
@@ -236,18 +515,42 @@ func (p *Propr) Generate(target string) (string, error) {
 			},
 			{
 				Role:    MessageRoleUser,
-				Content: strings.Join(commits, "\n"),
+				Content: commitMessages,
 			},
-			{
+		}
+
+		// Additional context is only part of the conversation when there is something to tell
+		previous := "commit messages"
+		for _, extra := range []struct {
+			name    string
+			content string
+		}{
+			{"changed files", generateFileTable(stats)},
+			{"changes to the exported API", summarizeAPIChanges(apiChanges)},
+		} {
+			if extra.content == "" {
+				continue
+			}
+
+			messages = append(messages, Message{
 				Role:    MessageRoleAssistant,
-				Content: "Thanks for providing the commit messages. Now the final step to generate a description is to see what's changed using the diff",
-			},
-			{
+				Content: fmt.Sprintf("Thanks for providing the %s. What about the %s?", previous, extra.name),
+			}, Message{
 				Role:    MessageRoleUser,
-				Content: prepareDiff(diff),
-			},
+				Content: extra.content,
+			})
+
+			previous = extra.name
 		}
 
+		messages = append(messages, Message{
+			Role:    MessageRoleAssistant,
+			Content: fmt.Sprintf("Thanks for providing the %s. Now the final step to generate a description is to see what's changed using the diff", previous),
+		}, Message{
+			Role:    MessageRoleUser,
+			Content: prepared,
+		})
+
 		log.Debug("Constructed messages to send to the provider", "messages", messages)
 
 		client := NewMessageClient(p.model)
@@ -260,6 +563,33 @@ func (p *Propr) Generate(target string) (string, error) {
 		}
 
 		description = response
+
+		// Breaking changes are determined from the AST rather than left to the model so releases can rely on them
+		if section := generateBreakingChangesSection(apiChanges); section != "" {
+			description = strings.TrimSpace(description) + "\n\n" + section
+		}
+
+		// Co-authors and referenced issues come straight from the commit trailers
+		if section := generateTrailersSection(commits); section != "" {
+			description = strings.TrimSpace(description) + "\n\n" + section
+		}
+
+		// Reviewers of stacked pull requests need to know what to review first
+		if section := generateStackSection(stack); section != "" {
+			description = strings.TrimSpace(description) + "\n\n" + section
+		}
+
+		if CONFIG.Data.ReviewersSection {
+			if section := generateReviewersSection(p.owners); section != "" {
+				description = strings.TrimSpace(description) + "\n\n" + section
+			}
+		}
+
+		if CONFIG.Data.FilesChanged {
+			if section := generateFilesChangedSection(stats); section != "" {
+				description = strings.TrimSpace(description) + "\n\n" + section
+			}
+		}
 	}).Run()
 	if err != nil {
 		return "", err
@@ -268,14 +598,162 @@ func (p *Propr) Generate(target string) (string, error) {
 	return description, nil
 }
 
-func (p *Propr) Create(target string, description string, draft bool) error {
-	gh := NewGitHub()
+// Connects to the repository on GitHub pull requests are opened against, the connection is reused afterwards
+func (p *Propr) connect() (*GitHub, error) {
+	if p.gh != nil {
+		return p.gh, nil
+	}
+
+	gh, err := NewGitHub(p.repo, p.baseRemote)
+	if err != nil {
+		return nil, err
+	}
+
+	p.gh = gh
+
+	return gh, nil
+}
 
-	// Use the target from the Propr instance if set, otherwise use the provided target or default branch
+// Use the target from the Propr instance if set, otherwise use the provided target or the branch head is stacked on.
+// The detected target is remembered so the pull request is created against the branch the description was generated for.
+func (p *Propr) resolveTarget(target string, head string) (string, error) {
 	if p.target != "" {
-		target = p.target
-	} else if target == "" {
-		target = gh.repo.GetDefaultBranch()
+		return p.target, nil
+	} else if target != "" {
+		return target, nil
+	}
+
+	defaultBranch, err := p.repo.DefaultBranch(p.baseRemote)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the default branch of %s, pass --branch to select a target: %w", p.baseRemote, err)
+	}
+
+	parent, ref, err := findStackParent(p.repo, p.baseRemote, head, defaultBranch)
+	if err != nil {
+		return "", err
+	}
+
+	if parent != defaultBranch {
+		log.Info("Targeting the branch this branch is stacked on, use --branch to pick another one", "branch", parent)
+	}
+
+	if ref == parent {
+		log.Warn("The branch this branch is stacked on hasn't been pushed yet, comparing against the local branch", "branch", parent)
+		p.localTarget = true
+	}
+
+	p.target = parent
+
+	return parent, nil
+}
+
+// Branches pushed to a fork have to be prefixed with the owner of the fork, eg. `forkowner:branch`
+func (p *Propr) headRef(owner string, branch string) (string, error) {
+	remote, remoteBranch, err := p.pushTarget(branch)
+	if err != nil {
+		return "", err
+	}
+
+	if remote == p.baseRemote {
+		return remoteBranch, nil
+	}
+
+	info, err := getRepositoryInformation(p.repo, remote)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Owner == owner {
+		return remoteBranch, nil
+	}
+
+	return info.Owner + ":" + remoteBranch, nil
+}
+
+// Where the branch lives on GitHub, which is its upstream or a branch of the same name on the head remote when it doesn't track one
+func (p *Propr) pushTarget(branch string) (string, string, error) {
+	remote, remoteBranch, err := p.repo.Upstream(branch)
+	if err != nil {
+		return "", "", err
+	}
+
+	if remote == "" {
+		return p.headRemote, branch, nil
+	}
+
+	return remote, remoteBranch, nil
+}
+
+// Makes sure the current branch and all of its commits are on its upstream before a pull request is opened,
+// otherwise GitHub refuses to create it or the description covers code the pull request doesn't contain
+func (p *Propr) EnsurePushed(push bool) error {
+	branch, err := p.currentBranch()
+	if err != nil {
+		return err
+	}
+
+	remote, name, err := p.pushTarget(branch)
+	if err != nil {
+		return err
+	}
+
+	remoteBranch := remote + "/" + name
+
+	var reason string
+	if !p.repo.HasRevision(remoteBranch) {
+		reason = fmt.Sprintf("Branch %s hasn't been pushed to %s yet", branch, remote)
+	} else {
+		ahead, behind, err := p.repo.AheadBehind(remoteBranch, branch)
+		if err != nil {
+			return err
+		}
+
+		if behind > 0 {
+			log.Warn("The remote branch has commits you don't have locally, pull them in before pushing", "branch", remoteBranch, "commits", behind)
+		}
+
+		if ahead == 0 {
+			return nil
+		}
+
+		reason = fmt.Sprintf("%d local commits aren't on %s yet", ahead, remoteBranch)
+		log.Warn("The description would describe code the pull request doesn't contain", "branch", remoteBranch, "commits", ahead)
+	}
+
+	if !push {
+		err = huh.NewConfirm().Title(reason + ", do you want to push?").Value(&push).Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !push {
+		return fmt.Errorf("push %s to %s before creating a pull request", branch, remoteBranch)
+	}
+
+	log.Debug("Pushing branch", "remote", remote, "branch", branch, "upstream", name)
+	return p.repo.Push(remote, branch, name)
+}
+
+func (p *Propr) Create(target string, description string, draft bool) error {
+	gh, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	// Get the current branch where changes are present
+	branch, err := p.currentBranch()
+	if err != nil {
+		return err
+	}
+
+	target, err = p.resolveTarget(target, branch)
+	if err != nil {
+		return err
+	}
+
+	if p.localTarget {
+		return fmt.Errorf("push %s to %s before creating a pull request that targets it, or pass --branch to pick another target", target, p.baseRemote)
 	}
 
 	token := os.Getenv("GITHUB_TOKEN")
@@ -283,24 +761,56 @@ func (p *Propr) Create(target string, description string, draft bool) error {
 		log.Fatal("GITHUB_TOKEN is not set")
 	}
 
+	format, err := parseTitleFormat(CONFIG.Data.TitleFormat)
+	if err != nil {
+		return err
+	}
+
+	// Start from a suggestion based on the description so the title only needs a review
 	var title string
-	err := huh.NewInput().Title("Provide a title for your pull request").Value(&title).Run()
+	if description != "" {
+		title = p.SuggestTitle(description, format)
+	}
+
+	title, err = inputTitle(title, format)
 	if err != nil {
 		return nil
 	}
 
-	// Get the current branch where changes are present
-	branch, err := getCurrentBranch()
-	if err != nil {
-		return err
+	p.addCodeOwners()
+
+	if p.selectMetadata {
+		err = gh.selectMetadata(&p.metadata)
+		if err != nil {
+			return nil
+		}
+	}
+
+	// Resolved before creating the pull request so a typo doesn't leave a pull request behind without its metadata
+	var milestone int
+	if p.metadata.Milestone != "" {
+		milestone, err = gh.milestone(p.metadata.Milestone)
+		if err != nil {
+			return err
+		}
 	}
 
 	owner := gh.repo.GetOwner().GetLogin()
 	name := gh.repo.GetName()
 
-	log.Debug("Creating pull request", "head", branch, "base", target, "owner", owner, "name", name)
+	head, err := p.headRef(owner, branch)
+	if err != nil {
+		return err
+	}
+
+	// The generated description is marked so regenerating it later leaves anything added by hand alone
+	if description != "" {
+		description = wrapGenerated(description)
+	}
+
+	log.Debug("Creating pull request", "head", head, "base", target, "owner", owner, "name", name)
 	pr, response, err := gh.client.PullRequests.Create(context.Background(), owner, name, &github.NewPullRequest{
-		Head:  github.String(branch),
+		Head:  github.String(head),
 		Base:  github.String(target),
 		Title: github.String(title),
 		Body:  github.String(description),
@@ -315,5 +825,9 @@ func (p *Propr) Create(target string, description string, draft bool) error {
 
 	fmt.Printf("Pull request created at %s\n", pr.GetHTMLURL())
 
-	return nil
+	if p.metadata.isEmpty() {
+		return nil
+	}
+
+	return gh.applyMetadata(pr, p.metadata, milestone)
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnsurePushedUsesUpstream(t *testing.T) {
+	dir := setupFixtureRepository(t)
+	runGit(t, dir, "push", "--quiet", "--set-upstream", "origin", "feature:renamed")
+	writeFile(t, dir, "e.txt", "more\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add e")
+
+	for name, repo := range openBackends(t, dir) {
+		p := &Propr{repo: repo, baseRemote: "origin", headRemote: "origin"}
+		if err := p.EnsurePushed(true); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		head, err := p.headRef("owner", "feature")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if head != "renamed" {
+			t.Errorf("%s: expected the pull request to be opened from renamed, got %s", name, head)
+		}
+	}
+
+	remote := runGit(t, dir, "ls-remote", "--heads", "origin")
+	if strings.Contains(remote, "refs/heads/feature") || runGit(t, dir, "rev-parse", "origin/renamed") != runGit(t, dir, "rev-parse", "feature") {
+		t.Errorf("expected the commits to be pushed to renamed only, got\n%s", remote)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+type SecretPattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Well known secret formats that should never leave the machine
+var SECRET_PATTERNS = []SecretPattern{
+	{"private-key", regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY( BLOCK)?-----`)},
+	{"aws-access-key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?(access_?)?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`)},
+	{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+}
+
+var (
+	candidateSecretRegex = regexp.MustCompile(`[A-Za-z0-9+/_=.-]{20,}`)
+	identifierRegex      = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	// Paths, import paths and host names like `github.com/aws/aws-sdk-go-v2/service/S3Client`
+	pathLikeRegex    = regexp.MustCompile(`^[\w-]*[./][\w./-]*$`)
+	pathSegmentRegex = regexp.MustCompile(`[\w-]+`)
+	// Assignments to names like `apiKey`, `client_secret` or `DB_PASSWORD`
+	secretAssignmentRegex = regexp.MustCompile(`(?i)(key|secret|token|passw(or)?d|credential|auth)\w*["']?\s*[:=]`)
+	diffHeaderPrefixes    = []string{"diff --git", "index ", "--- ", "+++ ", "@@ "}
+)
+
+const (
+	minimumSecretEntropy = 4.0
+	privateKeyEnd        = "PRIVATE KEY"
+)
+
+func redactionPlaceholder(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// Shannon entropy in bits per character
+func calculateEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	frequencies := make(map[rune]float64)
+	for _, r := range value {
+		frequencies[r]++
+	}
+
+	length := float64(len(value))
+	entropy := 0.0
+	for _, count := range frequencies {
+		p := count / length
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// Random looking strings are only considered a secret when they mix upper, lower case and digits
+// so that hex hashes don't get caught in the crossfire. Strings of only letters and digits look just like
+// identifiers such as `k8sClusterRoleBindingV1beta1Name`, so those only count when assigned to a secret-like name.
+func looksLikeSecret(value string, assignedToSecret bool) bool {
+	if identifierRegex.MatchString(value) && !assignedToSecret {
+		return false
+	}
+
+	var upper, lower, digit bool
+	for _, r := range value {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+
+	return upper && lower && digit && calculateEntropy(value) >= minimumSecretEntropy
+}
+
+func isDiffHeader(line string) bool {
+	for _, prefix := range diffHeaderPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redacts any known secret patterns or high entropy strings from the content
+// and returns the redacted content together with the amount of redactions
+func redactSecrets(content string) (string, int) {
+	lines := strings.Split(content, "\n")
+	count := 0
+	insidePrivateKey := false
+
+	for i, line := range lines {
+		if isDiffHeader(line) {
+			continue
+		}
+
+		// Everything between the begin and end marker of a private key is sensitive
+		if insidePrivateKey {
+			if strings.Contains(line, "-----END") && strings.Contains(line, privateKeyEnd) {
+				insidePrivateKey = false
+			}
+
+			lines[i] = line[:min(1, len(line))] + redactionPlaceholder("private-key")
+			continue
+		}
+
+		for _, secret := range SECRET_PATTERNS {
+			if !secret.Pattern.MatchString(line) {
+				continue
+			}
+
+			if secret.Name == "private-key" && !strings.Contains(line, "-----END") {
+				insidePrivateKey = true
+			}
+
+			count += len(secret.Pattern.FindAllStringIndex(line, -1))
+			line = secret.Pattern.ReplaceAllString(line, redactionPlaceholder(secret.Name))
+		}
+
+		assignedToSecret := secretAssignmentRegex.MatchString(line)
+		redact := func(match string) string {
+			if !looksLikeSecret(match, assignedToSecret) {
+				return match
+			}
+
+			count++
+			return redactionPlaceholder("high-entropy")
+		}
+
+		line = candidateSecretRegex.ReplaceAllStringFunc(line, func(match string) string {
+			// Only the segments of a path can hold a secret, the path as a whole is as random as the names in it
+			if pathLikeRegex.MatchString(match) {
+				return pathSegmentRegex.ReplaceAllStringFunc(match, redact)
+			}
+
+			return redact(match)
+		})
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n"), count
+}
+
+// Redacts secrets from every chunk and returns the affected files
+func redactChunks(chunks []string) ([]string, []string) {
+	var files []string
+
+	result := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		// The first line holds the paths of the file since the chunks no longer start with `diff --git`
+		header, body, ok := strings.Cut(chunk, "\n")
+		if !ok {
+			result[i] = chunk
+			continue
+		}
+
+		redacted, count := redactSecrets(body)
+		redacted = header + "\n" + redacted
+		if count > 0 {
+			log.Debug("Redacted secrets", "file", getChunkPath(chunk), "count", count)
+			files = append(files, getChunkPath(chunk))
+		}
+
+		result[i] = redacted
+	}
+
+	return result, files
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// scp-like syntax of ssh remotes, eg. `git@github.com:owner/repo.git`
+var scpLikeRegex = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+type RemoteURL struct {
+	Host string
+	// Everything between the host and the repository name, can be nested like GitLab subgroups
+	Owner string
+	Name  string
+}
+
+// Parses the URL of a remote in any of the shapes git accepts (scp-like, ssh://, git://, http(s)://)
+func parseRemoteURL(raw string) (*RemoteURL, error) {
+	raw = strings.TrimSpace(raw)
+
+	var host, path string
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote URL %q: %w", raw, err)
+		}
+
+		if u.Scheme == "file" {
+			return nil, fmt.Errorf("remote %q points to a local repository", raw)
+		}
+
+		// The port of an ssh remote doesn't say anything about the host of the forge itself
+		host = u.Host
+		if u.Scheme == "ssh" || u.Scheme == "git+ssh" {
+			host = u.Hostname()
+		}
+
+		path = u.Path
+	} else if match := scpLikeRegex.FindStringSubmatch(raw); match != nil {
+		host, path = match[1], match[2]
+	} else {
+		return nil, fmt.Errorf("unsupported remote URL %q", raw)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	segments := strings.Split(path, "/")
+	if host == "" || len(segments) < 2 {
+		return nil, fmt.Errorf("remote URL %q is missing an owner or repository", raw)
+	}
+
+	return &RemoteURL{
+		Host:  strings.ToLower(host),
+		Owner: strings.Join(segments[:len(segments)-1], "/"),
+		Name:  segments[len(segments)-1],
+	}, nil
+}
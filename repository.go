@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Everything propr needs to know about the git repository it is run in.
+// Besides a revision, the head of a diff can also be HeadStaged or HeadWorkingTree.
+type Repository interface {
+	CurrentBranch() (string, error)
+	// Local and remote branches without their remote prefix, sorted alphabetically
+	Branches() ([]string, error)
+	// Local branches (`branch`) and remote-tracking branches of the remote (`remote/branch`) whose tip is reachable
+	// from revision, only lists what has already been fetched
+	MergedBranches(remote string, revision string) ([]string, error)
+	Diff(base string, head string) (string, error)
+	// Same as Diff but every hunk is expanded to the function or type it belongs to
+	FunctionContextDiff(base string, head string) (string, error)
+	// Same as Diff but ignores any changes to whitespace and blank lines
+	WhitespaceInsensitiveDiff(base string, head string) (string, error)
+	// Output of `git diff --numstat --find-renames --summary`
+	DiffStat(base string, head string) (string, error)
+	ChangedFiles(base string, head string) ([]string, error)
+	// Contents of a file at the given revision, an empty string is returned when the file doesn't exist
+	FileAtRevision(revision string, path string) (string, error)
+	// Commits in head that aren't in base, newest first
+	Log(base string, head string) ([]Commit, error)
+	Remotes() ([]string, error)
+	RemoteURL(remote string) (string, error)
+	// Branch the HEAD of the remote points to
+	DefaultBranch(remote string) (string, error)
+	MergeBase(a string, b string) (string, error)
+	HasRevision(revision string) bool
+	// Full hash of the commit the revision points to
+	ResolveRevision(revision string) (string, error)
+	// Amount of commits head has that base doesn't and the other way around
+	AheadBehind(base string, head string) (int, int, error)
+	// Remote and name of the branch on that remote the branch tracks, both are empty when no upstream is set
+	Upstream(branch string) (string, string, error)
+	// Pushes the branch to remoteBranch on the remote and sets that as its upstream
+	Push(remote string, branch string, remoteBranch string) error
+	// Fetches a single branch into its remote-tracking branch
+	Fetch(remote string, branch string) error
+	Layout() (RepositoryLayout, error)
+}
+
+// How the repository is laid out on disk, which determines what propr can do with it
+type RepositoryLayout struct {
+	Bare    bool
+	Shallow bool
+	GitDir  string
+	// Git directory shared by all worktrees of the repository
+	CommonDir string
+}
+
+func (l RepositoryLayout) IsWorktree() bool {
+	return l.GitDir != l.CommonDir
+}
+
+// Special heads to describe work that hasn't been committed yet
+const (
+	HeadStaged      = ":staged"
+	HeadWorkingTree = ":working-tree"
+)
+
+func isUncommitted(head string) bool {
+	return head == HeadStaged || head == HeadWorkingTree
+}
+
+const (
+	BackendGit   = "git"
+	BackendGoGit = "go-git"
+)
+
+// Opens the repository in the given directory with the configured backend, the git binary is used by default
+func NewRepository(backend string, dir string) (Repository, error) {
+	switch backend {
+	case "", BackendGit:
+		return NewGitCLI(dir), nil
+	case BackendGoGit:
+		return NewGoGit(dir)
+	default:
+		return nil, fmt.Errorf("unknown git backend %q", backend)
+	}
+}
+
+// Deduplicates the local and remote branches, remote branches lose their remote prefix for display
+// Strips `refs/heads/` and `refs/remotes/` from branch refs, the symbolic ref to the default branch of the remote is skipped
+func shortBranchRef(ref string, remote string) (string, bool) {
+	if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		return branch, true
+	}
+
+	if branch, ok := strings.CutPrefix(ref, "refs/remotes/"+remote+"/"); ok && branch != "HEAD" {
+		return remote + "/" + branch, true
+	}
+
+	return "", false
+}
+
+func combineBranches(local []string, remote []string) []string {
+	allBranches := make(map[string]bool)
+	for _, branch := range local {
+		if branch != "" {
+			allBranches[branch] = true
+		}
+	}
+
+	for _, branch := range remote {
+		if branch == "" || strings.HasSuffix(branch, "/HEAD") || !strings.Contains(branch, "/") {
+			continue
+		}
+
+		allBranches[strings.SplitN(branch, "/", 2)[1]] = true
+	}
+
+	var result []string
+	for branch := range allBranches {
+		result = append(result, branch)
+	}
+
+	// Sort branches alphabetically for better UX
+	sort.Strings(result)
+
+	return result
+}
+
+const (
+	DefaultRemote  = "origin"
+	UpstreamRemote = "upstream"
+)
+
+// Determines the remote pull requests are opened against and the remote the branch is pushed to.
+// In a fork based workflow `origin` is the fork and `upstream` the original repository.
+func resolveRemotes(repo Repository, base string, head string) (string, string, error) {
+	if base != "" && head != "" {
+		return base, head, nil
+	}
+
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return "", "", err
+	}
+
+	hasUpstream := false
+	for _, remote := range remotes {
+		if remote == UpstreamRemote {
+			hasUpstream = true
+		}
+	}
+
+	if head == "" {
+		head = DefaultRemote
+	}
+
+	if base == "" {
+		base = head
+		if hasUpstream {
+			base = UpstreamRemote
+		}
+	}
+
+	return base, head, nil
+}
+
+type RepositoryInformation struct {
+	Host  string
+	Name  string
+	Owner string
+}
+
+func getRepositoryInformation(repo Repository, remote string) (*RepositoryInformation, error) {
+	url, err := repo.RemoteURL(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseRemoteURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepositoryInformation{
+		Host:  parsed.Host,
+		Name:  parsed.Name,
+		Owner: parsed.Owner,
+	}, nil
+}
+
+// Web URL of the repository behind the remote, eg. `https://github.com/owner/repo`
+func repositoryURL(repo Repository, remote string) (string, error) {
+	info, err := getRepositoryInformation(repo, remote)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("https://%s/%s/%s", info.Host, info.Owner, info.Name), nil
+}
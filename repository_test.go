@@ -0,0 +1,307 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Sets up a clone of a bare `origin` with a `feature` branch that edits, renames and adds files on top of `main`
+func setupFixtureRepository(t *testing.T) string {
+	t.Helper()
+
+	// Keep the configuration of the machine running the tests out of the fixture
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Propr")
+	t.Setenv("GIT_AUTHOR_EMAIL", "propr@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Propr")
+	t.Setenv("GIT_COMMITTER_EMAIL", "propr@example.com")
+
+	root := t.TempDir()
+	origin, seed, dir := filepath.Join(root, "origin.git"), filepath.Join(root, "seed"), filepath.Join(root, "work")
+
+	runGit(t, root, "init", "--quiet", "--bare", "--initial-branch=main", origin)
+	runGit(t, root, "init", "--quiet", "--initial-branch=main", seed)
+	writeFile(t, seed, "a.txt", "one\ntwo\nthree\n")
+	writeFile(t, seed, "b.txt", strings.Repeat("a line that stays the same\n", 10))
+	runGit(t, seed, "add", ".")
+	runGit(t, seed, "commit", "--quiet", "-m", "Initial commit")
+	runGit(t, seed, "push", "--quiet", origin, "main")
+
+	runGit(t, root, "clone", "--quiet", origin, dir)
+	runGit(t, dir, "checkout", "--quiet", "-b", "feature")
+	writeFile(t, dir, "a.txt", "one\n2\nthree\n")
+	runGit(t, dir, "commit", "--quiet", "-am", "Change the second line")
+	runGit(t, dir, "mv", "b.txt", "c.txt")
+	writeFile(t, dir, "d.txt", "new\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Rename b and add d\n\nKeeps the history of b.\n\nCo-authored-by: Jane <jane@example.com>\nFixes: #12")
+
+	return dir
+}
+
+func openBackends(t *testing.T, dir string) map[string]Repository {
+	t.Helper()
+
+	goGit, err := NewGoGit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Repository{
+		BackendGit:   NewGitCLI(dir),
+		BackendGoGit: goGit,
+	}
+}
+
+// Runs the check against every backend and makes sure they all come to the same result
+func assertBackendsAgree[T any](t *testing.T, backends map[string]Repository, expected T, check func(Repository) (T, error)) {
+	t.Helper()
+
+	for name, repo := range backends {
+		actual, err := check(repo)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %#v, got %#v", name, expected, actual)
+		}
+	}
+}
+
+func TestRepositoryBackends(t *testing.T) {
+	dir := setupFixtureRepository(t)
+	backends := openBackends(t, dir)
+	base := runGit(t, dir, "rev-parse", "main")
+	feature := runGit(t, dir, "rev-parse", "feature")
+
+	t.Run("current branch", func(t *testing.T) {
+		assertBackendsAgree(t, backends, "feature", func(repo Repository) (string, error) {
+			return repo.CurrentBranch()
+		})
+	})
+
+	t.Run("branches", func(t *testing.T) {
+		assertBackendsAgree(t, backends, []string{"feature", "main"}, func(repo Repository) ([]string, error) {
+			return repo.Branches()
+		})
+	})
+
+	t.Run("merged branches", func(t *testing.T) {
+		assertBackendsAgree(t, backends, []string{"feature", "main", "origin/main"}, func(repo Repository) ([]string, error) {
+			return repo.MergedBranches("origin", "feature")
+		})
+
+		assertBackendsAgree(t, backends, []string{"main", "origin/main"}, func(repo Repository) ([]string, error) {
+			return repo.MergedBranches("origin", "main")
+		})
+	})
+
+	t.Run("changed files", func(t *testing.T) {
+		assertBackendsAgree(t, backends, []string{"a.txt", "c.txt", "d.txt"}, func(repo Repository) ([]string, error) {
+			files, err := repo.ChangedFiles("main", "feature")
+			slices.Sort(files)
+			return files, err
+		})
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		for name, repo := range backends {
+			diff, err := repo.Diff("main", "feature")
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			for _, expected := range []string{"rename from b.txt", "rename to c.txt", "-two", "+2", "+new"} {
+				if !strings.Contains(diff, expected) {
+					t.Errorf("%s: expected the diff to contain %q:\n%s", name, expected, diff)
+				}
+			}
+
+			if chunks := splitDiffIntoChunks(diff); len(chunks) != 3 {
+				t.Errorf("%s: expected 3 files in the diff, got %d", name, len(chunks))
+			}
+		}
+	})
+
+	t.Run("log", func(t *testing.T) {
+		expected := []Commit{
+			{
+				Hash:    feature,
+				Subject: "Rename b and add d",
+				Body:    "Keeps the history of b.",
+				Trailers: []Trailer{
+					{Key: "Co-authored-by", Value: "Jane <jane@example.com>"},
+					{Key: "Fixes", Value: "#12"},
+				},
+			},
+			{
+				Hash:    runGit(t, dir, "rev-parse", "feature~1"),
+				Subject: "Change the second line",
+			},
+		}
+
+		assertBackendsAgree(t, backends, expected, func(repo Repository) ([]Commit, error) {
+			return repo.Log("main", "feature")
+		})
+	})
+
+	t.Run("file at revision", func(t *testing.T) {
+		assertBackendsAgree(t, backends, []string{"one\n2\nthree\n", "", "one\ntwo\nthree\n"}, func(repo Repository) ([]string, error) {
+			var contents []string
+			for _, revision := range []string{"feature:a.txt", "main:d.txt", "main:a.txt"} {
+				rev, path, _ := strings.Cut(revision, ":")
+				content, err := repo.FileAtRevision(rev, path)
+				if err != nil {
+					return nil, err
+				}
+
+				contents = append(contents, content)
+			}
+
+			return contents, nil
+		})
+	})
+
+	t.Run("merge base", func(t *testing.T) {
+		assertBackendsAgree(t, backends, base, func(repo Repository) (string, error) {
+			return repo.MergeBase("origin/main", "feature")
+		})
+	})
+
+	t.Run("ahead and behind", func(t *testing.T) {
+		assertBackendsAgree(t, backends, [2]int{2, 0}, func(repo Repository) ([2]int, error) {
+			ahead, behind, err := repo.AheadBehind("origin/main", "feature")
+			return [2]int{ahead, behind}, err
+		})
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		assertBackendsAgree(t, backends, []bool{true, true, false}, func(repo Repository) ([]bool, error) {
+			return []bool{repo.HasRevision("main"), repo.HasRevision(feature), repo.HasRevision("missing")}, nil
+		})
+
+		assertBackendsAgree(t, backends, feature, func(repo Repository) (string, error) {
+			return repo.ResolveRevision("feature")
+		})
+	})
+
+	t.Run("remotes", func(t *testing.T) {
+		assertBackendsAgree(t, backends, []string{"origin"}, func(repo Repository) ([]string, error) {
+			return repo.Remotes()
+		})
+
+		assertBackendsAgree(t, backends, filepath.Join(filepath.Dir(dir), "origin.git"), func(repo Repository) (string, error) {
+			return repo.RemoteURL("origin")
+		})
+	})
+
+	t.Run("upstream", func(t *testing.T) {
+		runGit(t, dir, "push", "--quiet", "--set-upstream", "origin", "feature:renamed")
+		defer runGit(t, dir, "branch", "--unset-upstream", "feature")
+
+		assertBackendsAgree(t, backends, [][2]string{{"origin", "main"}, {"origin", "renamed"}, {"", ""}}, func(repo Repository) ([][2]string, error) {
+			var upstreams [][2]string
+			for _, branch := range []string{"main", "feature", "missing"} {
+				remote, name, err := repo.Upstream(branch)
+				if err != nil {
+					return nil, err
+				}
+
+				upstreams = append(upstreams, [2]string{remote, name})
+			}
+
+			return upstreams, nil
+		})
+	})
+
+	t.Run("default branch", func(t *testing.T) {
+		assertBackendsAgree(t, backends, "main", func(repo Repository) (string, error) {
+			return repo.DefaultBranch("origin")
+		})
+
+		// Without the symbolic ref the remote is asked for its HEAD
+		runGit(t, dir, "remote", "set-head", "origin", "--delete")
+		assertBackendsAgree(t, backends, "main", func(repo Repository) (string, error) {
+			return repo.DefaultBranch("origin")
+		})
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		gitDir := resolveSymlinks(filepath.Join(dir, ".git"))
+		worktree := filepath.Join(t.TempDir(), "worktree")
+		runGit(t, dir, "worktree", "add", "--quiet", "--detach", worktree, "main")
+
+		if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		layouts := map[string]RepositoryLayout{
+			dir:                          {GitDir: gitDir, CommonDir: gitDir},
+			filepath.Join(dir, "nested"): {GitDir: gitDir, CommonDir: gitDir},
+			worktree:                     {GitDir: filepath.Join(gitDir, "worktrees", "worktree"), CommonDir: gitDir},
+			// Not a repository at all
+			t.TempDir(): {},
+		}
+		for path, expected := range layouts {
+			layout, err := NewGitCLI(path).Layout()
+			if expected.GitDir == "" {
+				if err == nil {
+					t.Errorf("%s: expected an error, got %#v", path, layout)
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+
+			if layout != expected {
+				t.Errorf("%s: expected %#v, got %#v", path, expected, layout)
+			}
+		}
+	})
+
+	t.Run("detached head", func(t *testing.T) {
+		runGit(t, dir, "checkout", "--quiet", "--detach", "feature~1")
+		defer runGit(t, dir, "checkout", "--quiet", "feature")
+
+		assertBackendsAgree(t, backends, "", func(repo Repository) (string, error) {
+			return repo.CurrentBranch()
+		})
+
+		assertBackendsAgree(t, backends, runGit(t, dir, "rev-parse", "HEAD"), func(repo Repository) (string, error) {
+			return repo.ResolveRevision("HEAD")
+		})
+	})
+}
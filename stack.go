@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/go-github/v61/github"
+)
+
+// Finds the branch the head was stacked on, which is the local or remote branch whose tip is the closest ancestor of head.
+// Only the branches that have already been fetched are considered, the default branch wins ties so regular branches keep targeting it.
+// The ref to compare against is returned as well, which is the local branch when it hasn't been pushed.
+func findStackParent(repo Repository, remote string, head string, defaultBranch string) (string, string, error) {
+	defaultRef := remote + "/" + defaultBranch
+
+	merged, err := repo.MergedBranches(remote, head)
+	if err != nil {
+		log.Debug("Failed to list the branches head is based on", "head", head, "error", err)
+		return defaultBranch, defaultRef, nil
+	}
+
+	// Branches the default branch already contains can't be any closer to head than the default branch itself
+	excluded := map[string]bool{}
+	if repo.HasRevision(defaultRef) {
+		branches, err := repo.MergedBranches(remote, defaultRef)
+		if err != nil {
+			return "", "", err
+		}
+
+		for _, branch := range branches {
+			excluded[branch] = true
+		}
+	}
+
+	// Prefer the remote-tracking branch as that is what the pull request will target
+	candidates := map[string]string{defaultBranch: defaultRef}
+	for _, ref := range merged {
+		branch := strings.TrimPrefix(ref, remote+"/")
+		if branch == head || branch == defaultBranch || excluded[ref] {
+			continue
+		}
+
+		if _, ok := candidates[branch]; !ok || ref != branch {
+			candidates[branch] = ref
+		}
+	}
+
+	parent, closest := defaultBranch, -1
+	for branch, ref := range candidates {
+		// Commits on head that aren't on the branch
+		ahead, _, err := repo.AheadBehind(ref, head)
+		if err != nil {
+			log.Debug("Failed to compare branches", "branch", ref, "head", head, "error", err)
+			continue
+		}
+
+		if ahead == 0 {
+			// Nothing to stack on when head has no commits of its own yet
+			if branch == defaultBranch {
+				return defaultBranch, defaultRef, nil
+			}
+
+			continue
+		}
+
+		if closest == -1 || ahead < closest || (ahead == closest && preferParent(branch, ref, parent, candidates[parent], defaultBranch)) {
+			parent, closest = branch, ahead
+		}
+	}
+
+	return parent, candidates[parent], nil
+}
+
+// Breaks ties between branches at the same distance from head: the default branch, then pushed branches, then by name
+func preferParent(branch string, ref string, current string, currentRef string, defaultBranch string) bool {
+	switch {
+	case branch == defaultBranch || current == defaultBranch:
+		return branch == defaultBranch
+	case (ref != branch) != (currentRef != current):
+		return ref != branch
+	default:
+		return branch < current
+	}
+}
+
+type Stack struct {
+	// Branch the pull request is stacked on, empty when it targets the default branch
+	Parent string
+	// Open pull request of the parent branch, nil when the parent is the default branch or hasn't been opened yet
+	ParentPullRequest *github.PullRequest
+	// Open pull requests that target the branch itself
+	Children []*github.PullRequest
+}
+
+func (g *GitHub) findStack(parent string, branch string) (*Stack, error) {
+	ctx := context.Background()
+	owner, name := g.repo.GetOwner().GetLogin(), g.repo.GetName()
+	stack := &Stack{}
+
+	if parent != g.repo.GetDefaultBranch() {
+		stack.Parent = parent
+
+		pulls, _, err := g.client.PullRequests.List(ctx, owner, name, &github.PullRequestListOptions{
+			State: "open",
+			Head:  owner + ":" + parent,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pulls) > 0 {
+			stack.ParentPullRequest = pulls[0]
+		}
+	}
+
+	children, _, err := g.client.PullRequests.List(ctx, owner, name, &github.PullRequestListOptions{
+		State: "open",
+		Base:  branch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stack.Children = children
+
+	return stack, nil
+}
+
+// The pull requests in the stack only add context to the description, so only the parent branch is mentioned when GitHub can't be reached
+func (p *Propr) findStack(parent string, branch string) *Stack {
+	if os.Getenv("GITHUB_TOKEN") == "" {
+		return p.localStack(parent)
+	}
+
+	gh, err := p.connect()
+	if err != nil {
+		log.Debug("Failed to connect to GitHub", "error", err)
+		return p.localStack(parent)
+	}
+
+	stack, err := gh.findStack(parent, branch)
+	if err != nil {
+		log.Debug("Failed to determine the stack", "error", err)
+		return p.localStack(parent)
+	}
+
+	return stack
+}
+
+// Stack as far as it can be determined from the repository itself
+func (p *Propr) localStack(parent string) *Stack {
+	defaultBranch, err := p.repo.DefaultBranch(p.baseRemote)
+	if err != nil {
+		log.Debug("Failed to determine the default branch", "remote", p.baseRemote, "error", err)
+		return nil
+	}
+
+	if parent == defaultBranch {
+		return nil
+	}
+
+	return &Stack{Parent: parent}
+}
+
+// Lists the pull requests this one depends on and the ones that build on top of it, if any
+func generateStackSection(stack *Stack) string {
+	if stack == nil || (stack.Parent == "" && len(stack.Children) == 0) {
+		return ""
+	}
+
+	var lines []string
+	if pr := stack.ParentPullRequest; pr != nil {
+		lines = append(lines, fmt.Sprintf("- Depends on #%d (`%s`)", pr.GetNumber(), stack.Parent))
+	} else if stack.Parent != "" {
+		lines = append(lines, fmt.Sprintf("- Based on `%s`", stack.Parent))
+	}
+
+	for _, pr := range stack.Children {
+		lines = append(lines, fmt.Sprintf("- Followed by #%d (`%s`)", pr.GetNumber(), pr.GetHead().GetRef()))
+	}
+
+	return "## Stack\n\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestFindStackParent(t *testing.T) {
+	dir := setupFixtureRepository(t)
+
+	// `child` is stacked on `feature`, `other` forked off main separately
+	runGit(t, dir, "push", "--quiet", "origin", "feature")
+	runGit(t, dir, "checkout", "--quiet", "-b", "child")
+	writeFile(t, dir, "e.txt", "child\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add e")
+	runGit(t, dir, "checkout", "--quiet", "-b", "other", "main")
+	writeFile(t, dir, "f.txt", "other\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add f")
+	runGit(t, dir, "push", "--quiet", "origin", "other")
+	runGit(t, dir, "checkout", "--quiet", "-b", "empty", "feature")
+
+	// `top` is stacked on `wip`, which hasn't been pushed
+	runGit(t, dir, "checkout", "--quiet", "-b", "wip", "main")
+	writeFile(t, dir, "g.txt", "wip\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add g")
+	runGit(t, dir, "checkout", "--quiet", "-b", "top")
+	writeFile(t, dir, "h.txt", "top\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Add h")
+
+	tests := []struct {
+		head     string
+		expected string
+		ref      string
+	}{
+		{"child", "feature", "origin/feature"},
+		{"feature", "main", "origin/main"},
+		{"other", "main", "origin/main"},
+		// Branches without commits of their own aren't stacked on anything
+		{"empty", "main", "origin/main"},
+		{"top", "wip", "wip"},
+	}
+
+	for name, repo := range openBackends(t, dir) {
+		for _, test := range tests {
+			parent, ref, err := findStackParent(repo, "origin", test.head, "main")
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			if parent != test.expected || ref != test.ref {
+				t.Errorf("%s: expected %s to be stacked on %s (%s), got %s (%s)", name, test.head, test.expected, test.ref, parent, ref)
+			}
+		}
+	}
+}
+
+func TestLocalStack(t *testing.T) {
+	dir := setupFixtureRepository(t)
+	p := &Propr{repo: NewGitCLI(dir), baseRemote: "origin"}
+	t.Setenv("GITHUB_TOKEN", "")
+
+	if stack := p.findStack("main", "feature"); stack != nil {
+		t.Errorf("expected no stack for the default branch, got %#v", stack)
+	}
+
+	stack := p.findStack("feature", "child")
+	if stack == nil || stack.Parent != "feature" {
+		t.Fatalf("expected the stack to be based on feature, got %#v", stack)
+	}
+
+	if section := generateStackSection(stack); section != "## Stack\n\n- Based on `feature`" {
+		t.Errorf("unexpected stack section %q", section)
+	}
+}
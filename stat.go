@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type FileStatus string
+
+const (
+	FileStatusAdded    FileStatus = "added"
+	FileStatusDeleted  FileStatus = "deleted"
+	FileStatusModified FileStatus = "modified"
+	FileStatusRenamed  FileStatus = "renamed"
+)
+
+type FileStat struct {
+	Status    FileStatus
+	Path      string
+	OldPath   string
+	Additions int
+	Deletions int
+	Binary    bool
+	Mode      string
+}
+
+var (
+	braceRenameRegex = regexp.MustCompile(`^(.*)\{(.*) => (.*)\}(.*)$`)
+	summaryRegex     = regexp.MustCompile(`^(create|delete) mode \d+ (.+)$`)
+	modeChangeRegex  = regexp.MustCompile(`^mode change (\d+) => (\d+) (.+)$`)
+)
+
+// Expands the rename notation of numstat (`old => new` or `dir/{old => new}/file`) into both paths
+func parseRenamedPath(path string) (string, string) {
+	if match := braceRenameRegex.FindStringSubmatch(path); match != nil {
+		join := func(middle string) string {
+			return strings.ReplaceAll(match[1]+middle+match[4], "//", "/")
+		}
+
+		return join(match[2]), join(match[3])
+	}
+
+	if before, after, ok := strings.Cut(path, " => "); ok {
+		return before, after
+	}
+
+	return "", path
+}
+
+// Parses the output of `git diff --numstat --find-renames --summary`
+func parseDiffStat(output string) []FileStat {
+	var stats []*FileStat
+	byPath := map[string]*FileStat{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		// Lines of the summary are indented with a single space
+		if strings.HasPrefix(line, " ") {
+			line = strings.TrimSpace(line)
+
+			if match := summaryRegex.FindStringSubmatch(line); match != nil {
+				if stat, ok := byPath[match[2]]; ok {
+					stat.Status = FileStatusAdded
+					if match[1] == "delete" {
+						stat.Status = FileStatusDeleted
+					}
+				}
+			} else if match := modeChangeRegex.FindStringSubmatch(line); match != nil {
+				if stat, ok := byPath[match[3]]; ok {
+					stat.Mode = match[1] + " → " + match[2]
+				}
+			}
+
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		stat := &FileStat{Status: FileStatusModified}
+		stat.OldPath, stat.Path = parseRenamedPath(fields[2])
+		if stat.OldPath != "" {
+			stat.Status = FileStatusRenamed
+		}
+
+		// Binary files don't have line counts
+		if fields[0] == "-" {
+			stat.Binary = true
+		} else {
+			stat.Additions, _ = strconv.Atoi(fields[0])
+			stat.Deletions, _ = strconv.Atoi(fields[1])
+		}
+
+		stats = append(stats, stat)
+		byPath[stat.Path] = stat
+	}
+
+	result := make([]FileStat, len(stats))
+	for i, stat := range stats {
+		result[i] = *stat
+	}
+
+	return result
+}
+
+// Renders a compact markdown table of the changed files
+func generateFileTable(stats []FileStat) string {
+	if len(stats) == 0 {
+		return ""
+	}
+
+	lines := []string{
+		"| Status | File | + | - |",
+		"| ------ | ---- | - | - |",
+	}
+
+	for _, stat := range stats {
+		status := string(stat.Status)
+		if stat.Mode != "" {
+			status += fmt.Sprintf(" (mode %s)", stat.Mode)
+		}
+
+		path := fmt.Sprintf("`%s`", stat.Path)
+		if stat.Status == FileStatusRenamed {
+			path = fmt.Sprintf("`%s` → `%s`", stat.OldPath, stat.Path)
+		}
+
+		additions, deletions := strconv.Itoa(stat.Additions), strconv.Itoa(stat.Deletions)
+		if stat.Binary {
+			additions, deletions = "bin", "bin"
+		}
+
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s | %s |", status, path, additions, deletions))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Wraps the file table in a collapsible section so it doesn't clutter the description
+func generateFilesChangedSection(stats []FileStat) string {
+	table := generateFileTable(stats)
+	if table == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("<details>\n<summary>Files changed</summary>\n\n%s\n\n</details>", table)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+const TITLE_SYSTEM_MESSAGE = `You are responsible to write the title of a GitHub PR.
+You will be provided with the current branch name and the description of the PR.
+Summarize the change in a single short line written in the imperative mood, like a good commit subject.
+Only answer with the title itself, do not wrap it in quotes or markdown and do not include any other text.
+`
+
+const (
+	TitleFormatConventional = "conventional"
+	TitleFormatJira         = "jira"
+)
+
+type TitleFormat struct {
+	// Explains the format to the model
+	Instructions string
+	Regex        *regexp.Regexp
+}
+
+var TITLE_FORMATS = map[string]TitleFormat{
+	TitleFormatConventional: {
+		Instructions: "The title has to follow the Conventional Commits specification, eg. `feat(api): add pagination` or `fix: handle empty responses`.",
+		Regex:        regexp.MustCompile(`^(build|chore|ci|docs|feat|fix|perf|refactor|revert|style|test)(\([^()\s]+\))?!?: \S.*$`),
+	},
+	TitleFormatJira: {
+		Instructions: "The title has to start with the Jira issue key found in the branch name or description, eg. `ABC-123: Add pagination`.",
+		Regex:        regexp.MustCompile(`^(\[[A-Z][A-Z0-9]+-\d+\]|[A-Z][A-Z0-9]+-\d+:?) \S.*$`),
+	},
+}
+
+// Resolves the configured title format, which is either one of the known formats or a regular expression.
+// Nil is returned when titles aren't restricted.
+func parseTitleFormat(format string) (*TitleFormat, error) {
+	if format == "" {
+		return nil, nil
+	}
+
+	if known, ok := TITLE_FORMATS[format]; ok {
+		return &known, nil
+	}
+
+	regex, err := regexp.Compile(format)
+	if err != nil {
+		return nil, fmt.Errorf("title format %q is neither %s, %s nor a valid regular expression: %w", format, TitleFormatConventional, TitleFormatJira, err)
+	}
+
+	return &TitleFormat{
+		Instructions: fmt.Sprintf("The title has to match the regular expression `%s`.", format),
+		Regex:        regex,
+	}, nil
+}
+
+func (f *TitleFormat) Validate(title string) error {
+	if f == nil || f.Regex.MatchString(title) {
+		return nil
+	}
+
+	return fmt.Errorf("title doesn't match the configured format %s", f.Regex)
+}
+
+// Asks the model for a title that summarizes the description, an empty title is returned when that fails
+func (p *Propr) SuggestTitle(description string, format *TitleFormat) string {
+	branch, err := p.currentHead()
+	if err != nil {
+		log.Debug("Failed to determine the current branch", "error", err)
+	}
+
+	systemMessage := TITLE_SYSTEM_MESSAGE
+	if format != nil {
+		systemMessage += "\n" + format.Instructions
+	}
+
+	messages := []Message{
+		{
+			Role:    MessageRoleUser,
+			Content: branch,
+		},
+		{
+			Role:    MessageRoleAssistant,
+			Content: "Thanks for providing the branch. What about the description?",
+		},
+		{
+			Role:    MessageRoleUser,
+			Content: description,
+		},
+	}
+
+	var title string
+	err = spinner.New().TitleStyle(lipgloss.NewStyle()).Title("Generating a title...").Action(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		response, err := NewMessageClient(p.model).CreateMessage(ctx, systemMessage, messages)
+		if err != nil {
+			log.Debug("Failed to generate a title", "error", err)
+			return
+		}
+
+		// Models sometimes ignore the instructions and quote the title anyway
+		title = strings.Trim(strings.TrimSpace(strings.Split(strings.TrimSpace(response), "\n")[0]), "\"'`")
+	}).Run()
+	if err != nil {
+		log.Debug("Failed to generate a title", "error", err)
+	}
+
+	if err := format.Validate(title); err != nil {
+		log.Warn("The suggested title doesn't match the configured format", "title", title)
+	}
+
+	return title
+}
+
+// Lets the user edit the title, it has to match the configured format before the pull request is created or updated
+func inputTitle(title string, format *TitleFormat) (string, error) {
+	err := huh.NewInput().
+		Title("Provide a title for your pull request").
+		Value(&title).
+		Validate(format.Validate).
+		Run()
+
+	return strings.TrimSpace(title), err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/google/go-github/v61/github"
+)
+
+// GitHub filters pull requests on `owner:branch`, also when the branch lives in the same repository
+func (p *Propr) headFilter(owner string, branch string) (string, error) {
+	head, err := p.headRef(owner, branch)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(head, ":") {
+		head = owner + ":" + head
+	}
+
+	return head, nil
+}
+
+// Looks up the open pull request of the current branch, nil is returned when there is none
+func (p *Propr) FindPullRequest() (*github.PullRequest, error) {
+	gh, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := p.currentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	owner := gh.repo.GetOwner().GetLogin()
+	head, err := p.headFilter(owner, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Looking up pull request", "head", head, "owner", owner, "name", gh.repo.GetName())
+	pulls, _, err := gh.client.PullRequests.List(context.Background(), owner, gh.repo.GetName(), &github.PullRequestListOptions{
+		State: "open",
+		Head:  head,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+
+	return pulls[0], nil
+}
+
+// Replaces the description of the pull request, the title is only changed when a new one is given
+func (p *Propr) Update(pr *github.PullRequest, title string, description string) error {
+	gh, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	changes := &github.PullRequest{
+		Body: github.String(description),
+	}
+
+	if title != "" && title != pr.GetTitle() {
+		changes.Title = github.String(title)
+	}
+
+	log.Debug("Updating pull request", "number", pr.GetNumber())
+	updated, _, err := gh.client.PullRequests.Edit(context.Background(), gh.repo.GetOwner().GetLogin(), gh.repo.GetName(), pr.GetNumber(), changes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pull request updated at %s\n", updated.GetHTMLURL())
+
+	return nil
+}
+
+var (
+	addedLineStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedLineStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	hunkHeaderStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+)
+
+// Line based diff of the current and regenerated description, without the headers that point to temporary files
+func diffDescriptions(before string, after string, pretty bool) (string, error) {
+	// Bodies edited on GitHub use Windows line endings
+	before = strings.ReplaceAll(before, "\r\n", "\n")
+	after = strings.ReplaceAll(after, "\r\n", "\n")
+
+	diff, err := diffContents(strings.TrimSpace(before)+"\n", strings.TrimSpace(after)+"\n")
+	if err != nil {
+		return "", err
+	}
+
+	if diff == "" {
+		return "", nil
+	}
+
+	var lines []string
+	hunks := false
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		if strings.HasPrefix(line, "@@") {
+			hunks = true
+		}
+
+		if !hunks {
+			continue
+		}
+
+		if pretty {
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				line = hunkHeaderStyle.Render(line)
+			case strings.HasPrefix(line, "+"):
+				line = addedLineStyle.Render(line)
+			case strings.HasPrefix(line, "-"):
+				line = removedLineStyle.Render(line)
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Opens the URL in the default browser of the platform
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	return cmd.Start()
+}